@@ -0,0 +1,26 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ReadAccount reads the database account (e.g: its regions and its default
+// consistency).
+func (c *DocumentDB) ReadAccount(ctx context.Context, opts ...*RequestOptions) (*DatabaseAccount, error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		h   http.Header
+		acc DatabaseAccount
+	)
+	if _, err := c.client.Query(withResponseHeader(ctx, &h), "", nil, &acc, headers); err != nil {
+		return nil, err
+	}
+	acc.MaxMediaStorageUsageInMB, _ = strconv.ParseInt(h.Get(HEADER_MAX_MEDIA_STORAGE), 10, 64)
+	acc.CurrentMediaStorageUsageInMB, _ = strconv.ParseInt(h.Get(HEADER_MEDIA_STORAGE), 10, 64)
+	return &acc, nil
+}
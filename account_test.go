@@ -0,0 +1,59 @@
+package documentdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAccount(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		assert.Equal("/", r.URL.Path)
+		assert.NotEqual("", r.Header.Get(HEADER_AUTH))
+		w.Header().Set(HEADER_MAX_MEDIA_STORAGE, "10240")
+		w.Header().Set(HEADER_MEDIA_STORAGE, "1")
+		fmt.Fprint(w, `{
+			"_self": "",
+			"id": "account",
+			"_rid": "account.documents.azure.com",
+			"media": "//media/",
+			"addresses": "//addresses/",
+			"_dbs": "//dbs/",
+			"writableLocations": [{"name": "West US", "databaseAccountEndpoint": "https://account-westus.documents.azure.com:443/"}],
+			"readableLocations": [
+				{"name": "West US", "databaseAccountEndpoint": "https://account-westus.documents.azure.com:443/"},
+				{"name": "East US", "databaseAccountEndpoint": "https://account-eastus.documents.azure.com:443/"}
+			],
+			"enableMultipleWriteLocations": false,
+			"userConsistencyPolicy": {"defaultConsistencyLevel": "BoundedStaleness", "maxStalenessPrefix": 100, "maxIntervalInSeconds": 5},
+			"queryEngineConfiguration": "{\"maxSqlQueryInputLength\":262144,\"spatialMaxGeometryPointCount\":256}"
+		}`)
+	}))
+	defer s.Close()
+	c := &DocumentDB{&Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}}
+	acc, err := c.ReadAccount(context.Background())
+	assert.Nil(err)
+	assert.Equal("account", acc.Id)
+	assert.Equal("//media/", acc.Media)
+	assert.Equal("//dbs/", acc.Dbs)
+	assert.Equal([]Location{{Name: "West US", Endpoint: "https://account-westus.documents.azure.com:443/"}}, acc.WritableLocations)
+	assert.Len(acc.ReadableLocations, 2)
+	assert.Equal(&ConsistencyPolicy{DefaultConsistencyLevel: BoundedStaleness, MaxStalenessPrefix: 100, MaxIntervalInSeconds: 5}, acc.ConsistencyPolicy)
+	assert.Equal(float64(262144), acc.QueryEngineConfiguration["maxSqlQueryInputLength"])
+	assert.Equal(int64(10240), acc.MaxMediaStorageUsageInMB)
+	assert.Equal(int64(1), acc.CurrentMediaStorageUsageInMB)
+}
+
+func TestReadAccountLink(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "", (*Query)(nil)).Return("", nil)
+	c.ReadAccount(context.Background())
+	client.AssertCalled(t, "Query", "", (*Query)(nil))
+}
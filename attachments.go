@@ -0,0 +1,104 @@
+package documentdb
+
+import (
+	"context"
+	"io"
+)
+
+// Read attachment by self link
+func (c *DocumentDB) ReadAttachment(ctx context.Context, link string, opts ...*RequestOptions) (att *Attachment, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &att, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read all attachments by document self link
+func (c *DocumentDB) ReadAttachments(ctx context.Context, doc string, opts ...*RequestOptions) (atts []Attachment, err error) {
+	return c.QueryAttachments(ctx, doc, nil, opts...)
+}
+
+// Read all document attachments that satisfy a query
+func (c *DocumentDB) QueryAttachments(ctx context.Context, doc string, query *Query, opts ...*RequestOptions) (atts []Attachment, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Attachments []Attachment `json:"Attachments,omitempty"`
+		Count       int          `json:"_count,omitempty"`
+	}
+	_, err = c.client.Query(ctx, doc+"attachments/", query, &data, headers)
+	if atts = data.Attachments; err != nil {
+		atts = nil
+	}
+	return
+}
+
+// Create attachment that points to an external media (i.e: Attachment.Media)
+func (c *DocumentDB) CreateAttachment(ctx context.Context, doc string, body interface{}, opts ...*RequestOptions) (att *Attachment, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, doc+"attachments/", body, &att, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// UploadAttachment create attachment with the given media as its content.
+// The media is stored by the service, and streamed to it without buffering.
+// slug is the attachment id.
+func (c *DocumentDB) UploadAttachment(ctx context.Context, doc, slug, contentType string, media io.Reader, opts ...*RequestOptions) (att *Attachment, err error) {
+	headers, err := optionsHeaders(map[string]string{
+		HEADER_SLUG:    slug,
+		HEADER_CONTYPE: contentType,
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Upload(ctx, doc+"attachments/", media, &att, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ReadMedia read the content of an attachment by its media link. The caller
+// is responsible for closing the returned reader.
+func (c *DocumentDB) ReadMedia(ctx context.Context, link string, opts ...*RequestOptions) (io.ReadCloser, error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Download(ctx, link, headers)
+}
+
+// Replace attachment
+func (c *DocumentDB) ReplaceAttachment(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (att *Attachment, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &att, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Delete attachment, the media managed by the service is deleted with it
+func (c *DocumentDB) DeleteAttachment(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
+}
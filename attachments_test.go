@@ -0,0 +1,72 @@
+package documentdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadAttachment(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "self_link", (*Query)(nil)).Return("", nil)
+	c.ReadAttachment(context.Background(), "self_link")
+	client.AssertCalled(t, "Query", "self_link", (*Query)(nil))
+}
+
+func TestQueryAttachments(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "doclink/attachments/", (*Query)(nil)).Return("", nil)
+	client.On("Query", "doclink/attachments/", IdQuery("logo")).Return("", nil)
+	ctx := context.Background()
+	c.ReadAttachments(ctx, "doclink/")
+	c.QueryAttachments(ctx, "doclink/", IdQuery("logo"))
+	client.AssertCalled(t, "Query", "doclink/attachments/", (*Query)(nil))
+	client.AssertCalled(t, "Query", "doclink/attachments/", IdQuery("logo"))
+}
+
+func TestCreateAttachment(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Create", "doclink/attachments/", `{"id":"logo"}`).Return(nil)
+	c.CreateAttachment(context.Background(), "doclink/", `{"id":"logo"}`)
+	client.AssertCalled(t, "Create", "doclink/attachments/", `{"id":"logo"}`)
+}
+
+func TestUploadAttachment(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	headers := map[string]string{HEADER_SLUG: "logo", HEADER_CONTYPE: "image/png", HEADER_PARTITIONKEY: `["foo"]`}
+	client.On("Upload", "doclink/attachments/", headers).Return(nil)
+	c.UploadAttachment(context.Background(), "doclink/", "logo", "image/png", strings.NewReader("png"), &RequestOptions{PartitionKey: "foo"})
+	client.AssertCalled(t, "Upload", "doclink/attachments/", headers)
+}
+
+func TestReadMedia(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Download", "media/abc").Return(nil)
+	rc, err := c.ReadMedia(context.Background(), "media/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	client.AssertCalled(t, "Download", "media/abc")
+}
+
+func TestReplaceAttachment(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Replace", "att_link", "{}").Return(nil)
+	c.ReplaceAttachment(context.Background(), "att_link", "{}")
+	client.AssertCalled(t, "Replace", "att_link", "{}")
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Delete", "att_link").Return(nil)
+	c.DeleteAttachment(context.Background(), "att_link")
+	client.AssertCalled(t, "Delete", "att_link")
+}
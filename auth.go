@@ -4,8 +4,181 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
 )
 
+// Authorizer sets the "authorization" header of the requests.
+type Authorizer interface {
+	Authorize(req *Request) error
+}
+
+// MasterKey authorize the requests using the account master key.
+type MasterKey string
+
+// Sign the request with the master key
+func (k MasterKey) Authorize(req *Request) error {
+	// ids of name based links are case sensitive
+	rId := req.rId
+	if !nameBased(req.link) {
+		rId = strings.ToLower(rId)
+	}
+	parts := []string{strings.ToLower(req.Method), strings.ToLower(req.rType), rId, strings.ToLower(req.Header.Get(HEADER_XDATE)), strings.ToLower(req.Header.Get("Date")), ""}
+	sign, err := authorize(strings.Join(parts, "\n"), string(k))
+	if err != nil {
+		return err
+	}
+
+	masterToken := "master"
+	tokenVersion := "1.0"
+	req.Header.Set(HEADER_AUTH, url.QueryEscape("type="+masterToken+"&ver="+tokenVersion+"&sig="+sign))
+	return nil
+}
+
+// ResourceTokens authorize the requests using resource tokens (i.e:
+// Permission.Token). It's called with the link of the requested resource
+// (see Request.ResourceLink) and returns the token that grants access to it.
+// The tokens are escaped before they're sent, use EncodedResourceTokens for
+// tokens that are stored already escaped.
+//
+// Example:
+//
+//	auth := ResourceTokens(func(link string) (string, error) {
+//		return tokens.Get(link)
+//	})
+type ResourceTokens func(link string) (string, error)
+
+// Set the resource token of the requested resource
+func (f ResourceTokens) Authorize(req *Request) error {
+	token, err := f(req.ResourceLink())
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HEADER_AUTH, url.QueryEscape(token))
+	return nil
+}
+
+// EncodedResourceTokens is like ResourceTokens, for tokens that are already
+// escaped (e.g: as they're returned in the "authorization" header). They're
+// sent as is.
+type EncodedResourceTokens func(link string) (string, error)
+
+// Set the resource token of the requested resource
+func (f EncodedResourceTokens) Authorize(req *Request) error {
+	token, err := f(req.ResourceLink())
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HEADER_AUTH, token)
+	return nil
+}
+
+// ResourceToken authorize all the requests using the given resource token.
+func ResourceToken(token string) Authorizer {
+	return ResourceTokens(func(string) (string, error) {
+		return token, nil
+	})
+}
+
+// KeyProvider provides the account master keys. It's called again when both
+// keys were rejected, so it can load rotated keys (e.g: from a secret store).
+type KeyProvider interface {
+	MasterKeys() (primary, secondary string, err error)
+}
+
+// Keys is a static KeyProvider.
+type Keys struct {
+	Primary, Secondary string
+}
+
+func (k Keys) MasterKeys() (string, string, error) {
+	return k.Primary, k.Secondary, nil
+}
+
+// Implemented by authorizers that are able to switch to another key when a
+// request was rejected
+type keyRotator interface {
+	// Rotate reports whether the request should be signed again and retried
+	Rotate(req *Request) bool
+}
+
+// RotatingKey authorize the requests using the master keys of a KeyProvider.
+// The requests are signed with the primary key, and when a request is
+// rejected (401) the client switches to the secondary key and retries it.
+// If the secondary key is rejected too, the keys are loaded again from the
+// provider.
+//
+// Example:
+//
+//	auth, err := NewRotatingKey(Keys{Primary: primary, Secondary: secondary})
+//	// ...
+//	client := New(url, Config{Authorizer: auth})
+type RotatingKey struct {
+	provider KeyProvider
+	mu       sync.RWMutex
+	keys     [2]string
+	i        int // index of the current key
+}
+
+// NewRotatingKey loads the keys from the provider and returns a RotatingKey
+func NewRotatingKey(provider KeyProvider) (*RotatingKey, error) {
+	k := &RotatingKey{provider: provider}
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Load the keys from the provider, and start from the primary key
+func (k *RotatingKey) load() error {
+	primary, secondary, err := k.provider.MasterKeys()
+	if err != nil {
+		return err
+	}
+	if primary == "" {
+		return errors.New("missing primary key")
+	}
+	k.keys, k.i = [2]string{primary, secondary}, 0
+	return nil
+}
+
+// Key returns the key that is currently in use
+func (k *RotatingKey) Key() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[k.i]
+}
+
+// Sign the request with the current key
+func (k *RotatingKey) Authorize(req *Request) error {
+	return MasterKey(k.Key()).Authorize(req)
+}
+
+// Switch to the next key if the request was signed with the current one
+func (k *RotatingKey) Rotate(req *Request) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// sign a copy of the request to find out if the current key was rejected,
+	// or it was already rotated by a concurrent request
+	r := &Request{rId: req.rId, rType: req.rType, link: req.link, Request: req.Request.Clone(req.Context())}
+	if err := MasterKey(k.keys[k.i]).Authorize(r); err != nil || r.Header.Get(HEADER_AUTH) != req.Header.Get(HEADER_AUTH) {
+		return err == nil
+	}
+	if k.i == 0 && k.keys[1] != "" {
+		k.i = 1
+		return true
+	}
+	// both keys were rejected, look for new keys
+	keys, i := k.keys, k.i
+	if err := k.load(); err != nil || k.keys == keys {
+		k.keys, k.i = keys, i
+		return false
+	}
+	return true
+}
+
 func authorize(str, key string) (string, error) {
 	var ret string
 	enc := base64.StdEncoding
@@ -0,0 +1,148 @@
+package documentdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterKey(t *testing.T) {
+	assert := assert.New(t)
+	r, _ := http.NewRequest("GET", "link", &bytes.Buffer{})
+	req := ResourceRequest("/dbs/b5NCAA==/", r)
+	req.Header.Set(HEADER_XDATE, "Thu, 27 Apr 2017 00:51:12 GMT")
+	assert.Nil(MasterKey("YXJpZWwNCg==").Authorize(req))
+	auth, err := url.QueryUnescape(req.Header.Get(HEADER_AUTH))
+	assert.Nil(err)
+	assert.Regexp("^type=master&ver=1.0&sig=.+", auth)
+
+	assert.NotNil(MasterKey("invalid key").Authorize(req))
+}
+
+func TestResourceTokens(t *testing.T) {
+	assert := assert.New(t)
+	var links []string
+	auth := ResourceTokens(func(link string) (string, error) {
+		links = append(links, link)
+		if link == "dbs/db/colls/forbidden" {
+			return "", errors.New("no token")
+		}
+		return "type=resource&ver=1&sig=abc", nil
+	})
+
+	r, _ := http.NewRequest("GET", "link", &bytes.Buffer{})
+	req := ResourceRequest("dbs/db/colls/coll/docs/", r)
+	assert.Nil(auth.Authorize(req))
+	assert.Equal(url.QueryEscape("type=resource&ver=1&sig=abc"), req.Header.Get(HEADER_AUTH))
+
+	req = ResourceRequest("dbs/db/colls/coll/docs/doc", r)
+	assert.Nil(auth.Authorize(req))
+
+	req = ResourceRequest("dbs/db/colls/forbidden/", r)
+	assert.NotNil(auth.Authorize(req))
+	assert.Equal([]string{"dbs/db/colls/coll", "dbs/db/colls/coll/docs/doc", "dbs/db/colls/forbidden"}, links)
+
+	// tokens that contain '%' are escaped too
+	auth = ResourceTokens(func(string) (string, error) {
+		return "type=resource&ver=1&sig=a%b", nil
+	})
+	assert.Nil(auth.Authorize(req))
+	assert.Equal(url.QueryEscape("type=resource&ver=1&sig=a%b"), req.Header.Get(HEADER_AUTH))
+
+	// encoded tokens are sent as is
+	encoded := EncodedResourceTokens(func(string) (string, error) {
+		return url.QueryEscape("type=resource&ver=1&sig=abc"), nil
+	})
+	assert.Nil(encoded.Authorize(req))
+	assert.Equal(url.QueryEscape("type=resource&ver=1&sig=abc"), req.Header.Get(HEADER_AUTH))
+}
+
+func TestConfigAuthorizer(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "1"}`)
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{Authorizer: ResourceToken("type=resource&ver=1&sig=abc")}}
+	var doc Document
+	_, err := client.Query(context.Background(), "dbs/db/colls/coll/docs/1", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Equal(url.QueryEscape("type=resource&ver=1&sig=abc"), s.Header.Get(HEADER_AUTH))
+}
+
+type keysFunc func() (string, string, error)
+
+func (f keysFunc) MasterKeys() (string, string, error) { return f() }
+
+func TestRotatingKey(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		auths    []string
+		rejected = 1
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get(HEADER_AUTH))
+		if len(auths) <= rejected {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": "Unauthorized", "message": "invalid key"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+
+	primary, secondary := "YXJpZWwNCg==", "Zm9vYmFyCg=="
+	loads := 0
+	auth, err := NewRotatingKey(keysFunc(func() (string, string, error) {
+		loads++
+		return primary, secondary, nil
+	}))
+	assert.Nil(err)
+	client := &Client{Url: s.URL, Config: Config{Authorizer: auth}}
+
+	// primary key rejected, retried with the secondary key
+	var doc Document
+	_, err = client.Query(context.Background(), "dbs/db/colls/coll/docs/1", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Equal("1", doc.Id)
+	assert.Len(auths, 2)
+	assert.NotEqual(auths[0], auths[1])
+	assert.Equal(secondary, auth.Key())
+
+	// secondary key rejected, and the provider has no new keys
+	auths, rejected = nil, 3
+	_, err = client.Query(context.Background(), "dbs/db/colls/coll/docs/1", nil, &doc, nil)
+	assert.NotNil(err)
+	assert.Len(auths, 1)
+	assert.Equal(2, loads)
+	assert.Equal(secondary, auth.Key())
+
+	// keys were rotated, the provider returns the new ones
+	auths, rejected = nil, 1
+	primary, secondary = "bmV3a2V5Cg==", ""
+	_, err = client.Query(context.Background(), "dbs/db/colls/coll/docs/1", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Len(auths, 2)
+	assert.Equal(primary, auth.Key())
+
+	_, err = NewRotatingKey(Keys{})
+	assert.NotNil(err)
+}
+
+func TestMasterKeyNameBased(t *testing.T) {
+	assert := assert.New(t)
+	date := "Thu, 27 Apr 2017 00:51:12 GMT"
+	r, _ := http.NewRequest("GET", "link", &bytes.Buffer{})
+	req := ResourceRequest("dbs/DB/colls/Coll/docs/Doc", r)
+	req.Header.Set(HEADER_XDATE, date)
+	assert.Nil(MasterKey("YXJpZWwNCg==").Authorize(req))
+	// the ids of name based links are case sensitive
+	sig, err := authorize("get\ndocs\ndbs/DB/colls/Coll/docs/Doc\n"+strings.ToLower(date)+"\n\n", "YXJpZWwNCg==")
+	assert.Nil(err)
+	assert.Equal(url.QueryEscape("type=master&ver=1.0&sig="+sig), req.Header.Get(HEADER_AUTH))
+}
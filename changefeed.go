@@ -0,0 +1,256 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ChangeFeedCheckpoint is the position of a change feed in each of the
+// collection partition key ranges (i.e: range id => etag). It can be
+// serialised as JSON and given back to ChangeFeedOptions to resume the feed.
+type ChangeFeedCheckpoint map[string]string
+
+// ChangeFeedOptions controls the reading of a collection change feed.
+type ChangeFeedOptions struct {
+	// PageSize is the max number of changes fetched per round trip.
+	// Zero lets the server decide.
+	PageSize int
+	// StartFromBeginning reads the changes from the beginning of the feed
+	// when there's no checkpoint for a range. By default, only the changes
+	// made from now on are read.
+	StartFromBeginning bool
+	// Checkpoint resumes the feed from a previous position.
+	Checkpoint ChangeFeedCheckpoint
+}
+
+// ChangeFeedIterator walks over the changed documents of a collection, one
+// document at a time.
+//
+// Next returns false once the feed is caught up on all the partition key
+// ranges, calling Next again later polls for new changes.
+//
+// Example:
+//
+//	feed, err := coll.ChangeFeed(ctx, ChangeFeedOptions{Checkpoint: cp})
+//	// ...
+//	for feed.Next(ctx) {
+//		var doc MyDocument
+//		if err := feed.Decode(&doc); err != nil {
+//			// ...
+//		}
+//	}
+//	if err := feed.Err(); err != nil {
+//		// ...
+//	}
+//	cp = feed.Checkpoint()
+type ChangeFeedIterator struct {
+	c      *DocumentDB
+	coll   string
+	ctxf   func(context.Context) context.Context
+	opts   ChangeFeedOptions
+	cp     ChangeFeedCheckpoint
+	ranges []PartitionKeyRange
+	// index of the current range in the pass
+	i       int
+	started bool
+	// etag of the buffered page, committed once the page is consumed
+	pending, pendingRange string
+	page                  []json.RawMessage
+	cur                   json.RawMessage
+	closed                bool
+	err                   error
+}
+
+// Read the change feed of a collection by self link
+func (c *DocumentDB) ChangeFeed(ctx context.Context, coll string, opts ChangeFeedOptions) (*ChangeFeedIterator, error) {
+	return c.changeFeed(ctx, coll, opts, nil)
+}
+
+func (c *DocumentDB) changeFeed(ctx context.Context, coll string, opts ChangeFeedOptions, ctxf func(context.Context) context.Context) (*ChangeFeedIterator, error) {
+	it := &ChangeFeedIterator{
+		c:    c,
+		coll: coll,
+		ctxf: ctxf,
+		opts: opts,
+		cp:   make(ChangeFeedCheckpoint, len(opts.Checkpoint)),
+	}
+	for k, v := range opts.Checkpoint {
+		it.cp[k] = v
+	}
+	if ctxf != nil {
+		ctx = ctxf(ctx)
+	}
+	if err := it.refresh(ctx); err != nil {
+		return nil, err
+	}
+	it.started = true
+	return it, nil
+}
+
+// Next advances the feed to the next changed document. It returns false when
+// the feed is caught up or an error occurred.
+func (it *ChangeFeedIterator) Next(ctx context.Context) bool {
+	it.cur = nil
+	if it.closed || it.err != nil {
+		return false
+	}
+	if it.ctxf != nil {
+		ctx = it.ctxf(ctx)
+	}
+	for len(it.page) == 0 {
+		it.commit()
+		if !it.started {
+			if it.err = it.refresh(ctx); it.err != nil {
+				return false
+			}
+			it.started = true
+		}
+		if it.i >= len(it.ranges) {
+			it.started = false
+			return false
+		}
+		r := it.ranges[it.i]
+		page, etag, err := it.fetch(ctx, r.Id)
+		// the range was split, continue from its child ranges
+		if gone(err) {
+			if it.err = it.split(ctx, r.Id, err); it.err != nil {
+				return false
+			}
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		// the range is caught up, move to the next one
+		if len(page) == 0 {
+			if etag != "" {
+				it.cp[r.Id] = etag
+			}
+			it.i++
+			continue
+		}
+		it.page, it.pending, it.pendingRange = page, etag, r.Id
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Reload the partition key ranges, ranges that were split continue from the
+// checkpoint of their parent.
+func (it *ChangeFeedIterator) refresh(ctx context.Context) error {
+	ranges, err := it.c.ReadPartitionKeyRanges(ctx, it.coll)
+	if err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		if _, ok := it.cp[r.Id]; ok {
+			continue
+		}
+		for i := len(r.Parents) - 1; i >= 0; i-- {
+			if etag, ok := it.cp[r.Parents[i]]; ok {
+				it.cp[r.Id] = etag
+				break
+			}
+		}
+	}
+	it.ranges, it.i = ranges, 0
+	return nil
+}
+
+// Replace a range that is gone by its child ranges, which continue from its
+// checkpoint. The pass starts over on the reloaded ranges.
+func (it *ChangeFeedIterator) split(ctx context.Context, rangeId string, err error) error {
+	if e := it.refresh(ctx); e != nil {
+		return e
+	}
+	for _, r := range it.ranges {
+		// the ranges are not updated yet
+		if r.Id == rangeId {
+			return err
+		}
+	}
+	delete(it.cp, rangeId)
+	return nil
+}
+
+// Reports whether the error is returned for a range that no longer exists
+// (i.e: it was split)
+func gone(err error) bool {
+	var e *RequestError
+	return errors.As(err, &e) && e.StatusCode == http.StatusGone
+}
+
+// Fetch the next page of changes of a partition key range
+func (it *ChangeFeedIterator) fetch(ctx context.Context, rangeId string) ([]json.RawMessage, string, error) {
+	etag, ok := it.cp[rangeId]
+	if !ok && !it.opts.StartFromBeginning {
+		etag = "*"
+	}
+	return it.c.readChanges(ctx, it.coll, rangeId, etag, it.opts.PageSize)
+}
+
+// Read a page of changes of a partition key range, starting from the given
+// etag ("*" for now, or empty for the beginning of the feed). It returns the
+// etag of the next page.
+func (c *DocumentDB) readChanges(ctx context.Context, coll, rangeId, etag string, pageSize int) ([]json.RawMessage, string, error) {
+	headers := map[string]string{
+		HEADER_A_IM:       "Incremental feed",
+		HEADER_PKRANGE_ID: rangeId,
+	}
+	if etag != "" {
+		headers[HEADER_IF_NONE_MATCH] = etag
+	}
+	var (
+		h    http.Header
+		page []json.RawMessage
+	)
+	ctx = withResponseHeader(ctx, &h)
+	if _, err := c.queryDocuments(ctx, coll, &Query{MaxItems: pageSize}, &page, headers); err != nil {
+		return nil, "", err
+	}
+	return page, h.Get(HEADER_ETAG), nil
+}
+
+// Commit the position of the consumed page
+func (it *ChangeFeedIterator) commit() {
+	if it.pendingRange != "" {
+		it.cp[it.pendingRange] = it.pending
+		it.pending, it.pendingRange = "", ""
+	}
+}
+
+// Decode the current document into the given value
+func (it *ChangeFeedIterator) Decode(v interface{}) error {
+	if it.closed {
+		return ErrIteratorClosed
+	}
+	if it.cur == nil {
+		return errors.New("no current document, call Next first")
+	}
+	return json.Unmarshal(it.cur, v)
+}
+
+// Checkpoint returns the position of the documents that were consumed so far.
+// The current page is included only after it is fully consumed.
+func (it *ChangeFeedIterator) Checkpoint() ChangeFeedCheckpoint {
+	cp := make(ChangeFeedCheckpoint, len(it.cp))
+	for k, v := range it.cp {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (it *ChangeFeedIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and releases the buffered page.
+func (it *ChangeFeedIterator) Close() error {
+	it.closed = true
+	it.page, it.cur = nil, nil
+	return nil
+}
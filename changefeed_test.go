@@ -0,0 +1,130 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeFeed(t *testing.T) {
+	assert := assert.New(t)
+	// range id => pages of changes, each page is served with the etag of its index
+	feeds := map[string][]string{
+		"0": {`[{"id": "1"}, {"id": "2"}]`, `[{"id": "3"}]`},
+		"1": {`[{"id": "4"}]`},
+	}
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/pkranges/") {
+			fmt.Fprint(w, `{"PartitionKeyRanges": [{"id": "0"}, {"id": "1"}]}`)
+			return
+		}
+		assert.Equal("Incremental feed", r.Header.Get(HEADER_A_IM))
+		id, etag := r.Header.Get(HEADER_PKRANGE_ID), r.Header.Get(HEADER_IF_NONE_MATCH)
+		requests = append(requests, id+":"+etag)
+		i := 0
+		fmt.Sscan(etag, &i)
+		if i >= len(feeds[id]) {
+			w.Header().Set(HEADER_ETAG, etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(HEADER_ETAG, fmt.Sprint(i+1))
+		fmt.Fprintf(w, `{"Documents": %s}`, feeds[id][i])
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	ctx := context.Background()
+
+	feed, err := c.ChangeFeed(ctx, "coll/", ChangeFeedOptions{StartFromBeginning: true})
+	assert.Nil(err)
+	var ids []string
+	for feed.Next(ctx) {
+		var doc Document
+		assert.Nil(feed.Decode(&doc))
+		ids = append(ids, doc.Id)
+		if doc.Id == "2" {
+			assert.Equal(ChangeFeedCheckpoint{}, feed.Checkpoint(), "the page is not consumed yet")
+		}
+	}
+	assert.Nil(feed.Err())
+	assert.Equal([]string{"1", "2", "3", "4"}, ids)
+	assert.Equal([]string{"0:", "0:1", "0:2", "1:", "1:1"}, requests)
+	cp := feed.Checkpoint()
+	assert.Equal(ChangeFeedCheckpoint{"0": "2", "1": "1"}, cp)
+
+	// Resume from a serialised checkpoint
+	b, err := json.Marshal(cp)
+	assert.Nil(err)
+	var resumed ChangeFeedCheckpoint
+	assert.Nil(json.Unmarshal(b, &resumed))
+	feeds["1"] = append(feeds["1"], `[{"id": "5"}]`)
+	requests = nil
+	feed, err = c.ChangeFeed(ctx, "coll/", ChangeFeedOptions{Checkpoint: resumed})
+	assert.Nil(err)
+	ids = nil
+	for feed.Next(ctx) {
+		var doc Document
+		assert.Nil(feed.Decode(&doc))
+		ids = append(ids, doc.Id)
+	}
+	assert.Nil(feed.Err())
+	assert.Equal([]string{"5"}, ids)
+	assert.Equal([]string{"0:2", "1:1", "1:2"}, requests)
+	assert.Equal(ChangeFeedCheckpoint{"0": "2", "1": "2"}, feed.Checkpoint())
+}
+
+func TestChangeFeedSplit(t *testing.T) {
+	assert := assert.New(t)
+	split := false
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/pkranges/") {
+			if split {
+				fmt.Fprint(w, `{"PartitionKeyRanges": [{"id": "1"}, {"id": "2", "parents": ["0"]}, {"id": "3", "parents": ["0"]}]}`)
+			} else {
+				fmt.Fprint(w, `{"PartitionKeyRanges": [{"id": "0"}, {"id": "1"}]}`)
+			}
+			return
+		}
+		id, etag := r.Header.Get(HEADER_PKRANGE_ID), r.Header.Get(HEADER_IF_NONE_MATCH)
+		requests = append(requests, id+":"+etag)
+		switch {
+		case id == "0" && etag == "":
+			w.Header().Set(HEADER_ETAG, "1")
+			fmt.Fprint(w, `{"Documents": [{"id": "1"}]}`)
+		case id == "0":
+			// the range is split after the first page
+			split = true
+			http.Error(w, `{"code": "Gone", "message": "partition key range is gone"}`, http.StatusGone)
+		case (id == "2" || id == "3") && etag == "1":
+			w.Header().Set(HEADER_ETAG, "2")
+			fmt.Fprintf(w, `{"Documents": [{"id": "%s"}]}`, id)
+		default:
+			w.Header().Set(HEADER_ETAG, etag)
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	ctx := context.Background()
+
+	feed, err := c.ChangeFeed(ctx, "coll/", ChangeFeedOptions{StartFromBeginning: true})
+	assert.Nil(err)
+	var ids []string
+	for feed.Next(ctx) {
+		var doc Document
+		assert.Nil(feed.Decode(&doc))
+		ids = append(ids, doc.Id)
+	}
+	assert.Nil(feed.Err())
+	assert.Equal([]string{"1", "2", "3"}, ids)
+	assert.Equal([]string{"0:", "0:1", "1:", "2:1", "2:2", "3:1", "3:2"}, requests)
+	assert.Equal(ChangeFeedCheckpoint{"2": "2", "3": "2"}, feed.Checkpoint())
+}
@@ -6,22 +6,56 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var (
-	ResponseHook  func(ctx context.Context, method string, headers map[string][]string)
+	// Deprecated: use Config.Middlewares instead.
+	ResponseHook func(ctx context.Context, method string, headers map[string][]string)
+	// Deprecated: use Config.IgnoreContext instead.
 	IgnoreContext bool
-	errRetry      = errors.New("retry")
 )
 
+// user agent of the requests, see Config.UserAgentSuffix
+const userAgent = "documentdb-go"
+
+// Handler sends a signed request and returns its response.
+type Handler func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Middleware wraps the Handler of a client to intercept its requests and
+// responses. It's called on each attempt of a request (e.g: retries).
+//
+// The error returned by the Handler is a transport error (e.g: the endpoint
+// is unreachable). Failed responses (e.g: 404 or 429) are returned without an
+// error, like http.Client does, so middlewares must check resp.StatusCode.
+// The *RequestError of a failed response is built after the middlewares
+// return, and the response body must be left unread.
+//
+// Example:
+//
+//	func Logger(next documentdb.Handler) documentdb.Handler {
+//		return func(ctx context.Context, req *documentdb.Request) (*http.Response, error) {
+//			start := time.Now()
+//			resp, err := next(ctx, req)
+//			if err != nil {
+//				log.Printf("%s %s %v %v", req.Method, req.ResourceType(), time.Since(start), err)
+//			} else {
+//				log.Printf("%s %s %v %d", req.Method, req.ResourceType(), time.Since(start), resp.StatusCode)
+//			}
+//			return resp, err
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
 type queryKey struct{}
 type sprocKey struct{}
 type collKey struct{}
+type respKey struct{}
 
 func CtxQuery(ctx context.Context) *Query {
 	q, _ := ctx.Value(queryKey{}).(*Query)
@@ -38,8 +72,16 @@ func CtxCollection(ctx context.Context) string {
 	return s
 }
 
+// Capture the response headers of the request made with the returned context
+func withResponseHeader(ctx context.Context, h *http.Header) context.Context {
+	return context.WithValue(ctx, respKey{}, h)
+}
+
 var (
 	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrConflict           = errors.New("conflict")
+	ErrThrottled          = errors.New("request rate is too large")
+	ErrRequestTooLarge    = errors.New("request entity too large")
 )
 
 type QueryParam struct {
@@ -48,9 +90,10 @@ type QueryParam struct {
 }
 
 type Query struct {
-	Text   string       `json:"query"`
-	Params []QueryParam `json:"parameters,omitempty"`
-	Token  string       `json:"-"` // continuation token
+	Text     string       `json:"query"`
+	Params   []QueryParam `json:"parameters,omitempty"`
+	Token    string       `json:"-"` // continuation token
+	MaxItems int          `json:"-"` // max items per page
 }
 
 // NewQuery create a query with given parameters.
@@ -71,16 +114,22 @@ func NewQuery(qu string, params map[string]interface{}) *Query {
 
 type Clienter interface {
 	Delete(ctx context.Context, link string, headers map[string]string) error
-	Query(ctx context.Context, link string, qu *Query, ret interface{}) (token string, err error)
+	Query(ctx context.Context, link string, qu *Query, ret interface{}, headers map[string]string) (token string, err error)
 	Create(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error
 	Replace(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error
-	Execute(ctx context.Context, link string, body, ret interface{}) error
+	Execute(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error
+	Upload(ctx context.Context, link string, media io.Reader, ret interface{}, headers map[string]string) error
+	Download(ctx context.Context, link string, headers map[string]string) (io.ReadCloser, error)
 }
 
 type Client struct {
 	Url    string
 	Config Config
 	Client *http.Client
+	// session tokens of the collections
+	sessions sessionTokens
+	// regional endpoints of the account
+	locations locations
 }
 
 // Delete resource by self link
@@ -90,7 +139,7 @@ func (c *Client) Delete(ctx context.Context, link string, headers map[string]str
 }
 
 // Query resource or read it by self link.
-func (c *Client) Query(ctx context.Context, link string, query *Query, out interface{}) (string, error) {
+func (c *Client) Query(ctx context.Context, link string, query *Query, out interface{}, headers map[string]string) (string, error) {
 	var (
 		method = "GET"
 		r      io.Reader
@@ -105,18 +154,21 @@ func (c *Client) Query(ctx context.Context, link string, query *Query, out inter
 		r = bytes.NewReader(data)
 		method = "POST"
 	}
-	hr, err := http.NewRequest(method, path(c.Url, link), r)
+	hr, err := http.NewRequest(method, path(c.Url, escapeLink(link)), r)
 	if err != nil {
 		return "", err
 	}
 	ctx = context.WithValue(ctx, queryKey{}, query)
 	req := ResourceRequest(link, hr)
-	if err = req.DefaultHeaders(c.Config.MasterKey); err != nil {
-		return "", err
+	for k, v := range headers {
+		req.Header.Add(k, v)
 	}
 	tok := ""
 	if query != nil {
 		tok = query.Token
+		if query.MaxItems > 0 {
+			req.Header.Set(HEADER_MAX_ITEMS, strconv.Itoa(query.MaxItems))
+		}
 	}
 	req.QueryHeaders(n, tok)
 	resp, err := c.do(ctx, req, out)
@@ -150,19 +202,48 @@ func (c *Client) Replace(ctx context.Context, link string, body, ret interface{}
 
 // Replace resource
 // TODO: DRY, move to methods instead of actions(POST, PUT, ...)
-func (c *Client) Execute(ctx context.Context, link string, body, ret interface{}) error {
+func (c *Client) Execute(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error {
 	data, err := stringify(body)
 	if err != nil {
 		return err
 	}
 	buf := bytes.NewBuffer(data)
-	_, err = c.method(ctx, "POST", link, ret, buf, nil)
+	_, err = c.method(ctx, "POST", link, ret, buf, headers)
 	return err
 }
 
+// Upload raw media, the content is streamed to the server as is.
+// Requests with a body that can't be rewound (i.e: not a bytes.Buffer,
+// bytes.Reader or strings.Reader) are not retried.
+func (c *Client) Upload(ctx context.Context, link string, media io.Reader, ret interface{}, headers map[string]string) error {
+	_, err := c.method(ctx, "POST", link, ret, media, headers)
+	return err
+}
+
+// Download raw media, the caller is responsible for closing the returned
+// reader.
+func (c *Client) Download(ctx context.Context, link string, headers map[string]string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	if _, err := c.method(ctx, "GET", link, &rc, nil, headers); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// SessionToken returns the session token of a collection by its link.
+func (c *Client) SessionToken(coll string) string {
+	return c.sessions.get(collectionLink(coll))
+}
+
+// SetSessionToken merges the given session token into the session of a
+// collection. It's used to continue a session that was started elsewhere.
+func (c *Client) SetSessionToken(coll, token string) {
+	c.sessions.merge(collectionLink(coll), token)
+}
+
 // Private generic method resource
 func (c *Client) method(ctx context.Context, method, link string, ret interface{}, body io.Reader, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest(method, path(c.Url, link), body)
+	req, err := http.NewRequest(method, path(c.Url, escapeLink(link)), body)
 	if err != nil {
 		return nil, err
 	}
@@ -170,79 +251,159 @@ func (c *Client) method(ctx context.Context, method, link string, ret interface{
 	for k, v := range headers {
 		r.Header.Add(k, v)
 	}
-	if err = r.DefaultHeaders(c.Config.MasterKey); err != nil {
-		return nil, err
-	}
 	return c.do(ctx, r, ret)
 }
 
-func retriable(code int) bool {
-	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+// Return the configured authorizer, or the master key one
+func (c *Client) authorizer() Authorizer {
+	if c.Config.Authorizer != nil {
+		return c.Config.Authorizer
+	}
+	return MasterKey(c.Config.MasterKey)
 }
 
-func (c *Client) checkResponse(ctx context.Context, retryCount int, resp *http.Response) error {
-	if retriable(resp.StatusCode) {
-		if retryCount < c.Config.MaxRetries {
-			delay := backoffDelay(retryCount)
-			t := time.NewTimer(delay)
-			select {
-			case <-ctx.Done():
-				t.Stop()
-				return ctx.Err()
-			case <-t.C:
-				return errRetry
-			}
-		}
+// Return the user agent of the requests
+func (c *Client) userAgent() string {
+	if c.Config.UserAgentSuffix != "" {
+		return userAgent + " " + c.Config.UserAgentSuffix
+	}
+	return userAgent
+}
+
+// Return the configured retry policy, or the default one
+func (c *Client) retryPolicy() RetryPolicy {
+	if c.Config.RetryPolicy != nil {
+		return c.Config.RetryPolicy
 	}
-	if resp.StatusCode == http.StatusPreconditionFailed {
-		return ErrPreconditionFailed
+	return &DefaultRetryPolicy{MaxRetries: c.Config.MaxRetries}
+}
+
+func (c *Client) checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
 	}
 	if resp.StatusCode >= 300 {
-		err := &RequestError{}
-		readJson(resp.Body, &err)
-		return err
+		return responseError(resp)
 	}
 
 	return nil
 }
 
-// Private Do function, DRY
-func (c *Client) do(ctx context.Context, r *Request, data interface{}) (*http.Response, error) {
+// Return the handler of the requests, wrapped by the configured middlewares.
+// The first middleware is the outermost.
+func (c *Client) handler() Handler {
 	cli := c.Client
 	if cli == nil {
 		cli = http.DefaultClient
 	}
-	if !IgnoreContext {
+	h := func(ctx context.Context, r *Request) (*http.Response, error) {
+		return cli.Do(r.Request)
+	}
+	for i := len(c.Config.Middlewares) - 1; i >= 0; i-- {
+		h = c.Config.Middlewares[i](h)
+	}
+	return h
+}
+
+// Private Do function, DRY
+func (c *Client) do(ctx context.Context, r *Request, data interface{}) (*http.Response, error) {
+	if c.hedged(ctx, r) {
+		return c.hedge(ctx, r, data)
+	}
+	send := c.handler()
+	if !IgnoreContext && !c.Config.IgnoreContext {
 		r.Request = r.Request.WithContext(ctx)
 	}
-	// save body to be able to retry the request
-	b, err := ioutil.ReadAll(r.Request.Body)
-	if err != nil {
-		return nil, err
+	if c.Config.ConsistencyLevel != "" && r.Header.Get(HEADER_CONSISTENCY) == "" {
+		r.Header.Set(HEADER_CONSISTENCY, string(c.Config.ConsistencyLevel))
 	}
-	retryCount := 0
-	for {
-		r.Request.Body = ioutil.NopCloser(bytes.NewReader(b))
-		resp, err := cli.Do(r.Request)
-		if err != nil {
+	c.sessions.apply(ctx, r)
+	r.Header.Set(HEADER_USER_AGENT, c.userAgent())
+	// streamed bodies are sent only once
+	rewindable := r.Request.Body == nil || r.Request.GetBody != nil
+	auth := c.authorizer()
+	policy := c.retryPolicy()
+	// the rotations of the key and the failovers are not retries, they're
+	// not counted by the retry policy
+	retries, rotations, failovers := 0, 0, 0
+	// reports whether the attempt is a retry of the policy
+	retry := false
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		// sign each attempt, the key may be rotated in between
+		if err := r.DefaultHeadersWith(auth); err != nil {
 			return nil, err
 		}
-		if ResponseHook != nil {
-			ResponseHook(ctx, r.Request.Method, resp.Header)
+		if attempt > 0 && r.Request.GetBody != nil {
+			body, err := r.Request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Request.Body = body
 		}
-		err = c.checkResponse(ctx, retryCount, resp)
-		if err == errRetry {
-			resp.Body.Close()
-			retryCount++
-			continue
+		ep := c.endpoint(ctx, r)
+		if ep != nil {
+			r.URL.Scheme, r.URL.Host, r.Host = ep.Scheme, ep.Host, ep.Host
+		}
+		resp, err := send(ctx, r)
+		if ep != nil && failovers < maxFailovers && rewindable && ctx.Err() == nil && (err != nil || resp.StatusCode >= 400) {
+			if c.failover(ctx, r, ep, resp, err) {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				failovers++
+				retry = false
+				continue
+			}
+		}
+		if err == nil {
+			c.sessions.capture(ctx, r, resp)
+			captureResponseInfo(ctx, resp, retry)
+			if ResponseHook != nil {
+				ResponseHook(ctx, r.Request.Method, resp.Header)
+			}
+			if resp.StatusCode == http.StatusUnauthorized && rotations < 2 && rewindable {
+				if k, ok := auth.(keyRotator); ok && k.Rotate(r) {
+					resp.Body.Close()
+					rotations++
+					retry = false
+					continue
+				}
+			}
+		}
+		if (err != nil || resp.StatusCode >= 400) && rewindable && ctx.Err() == nil {
+			a := Attempt{Request: r, Response: resp, Err: err, Count: retries + 1, Waited: waited}
+			if delay, ok := policy.Retry(a); ok {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+				waited += delay
+				retries++
+				retry = true
+				continue
+			}
 		}
-		defer resp.Body.Close()
-
 		if err != nil {
-			return resp, err
+			return nil, retryError(err, retries+1)
+		}
+		if err = c.checkResponse(resp); err != nil {
+			resp.Body.Close()
+			return resp, retryError(err, retries+1)
+		}
+		if h, ok := ctx.Value(respKey{}).(*http.Header); ok {
+			*h = resp.Header
+		}
+		// the body is handed over to the caller
+		if rc, ok := data.(*io.ReadCloser); ok {
+			*rc = resp.Body
+			return resp, nil
 		}
+		defer resp.Body.Close()
 
-		if data == nil {
+		if data == nil || resp.StatusCode == http.StatusNotModified {
 			return resp, nil
 		}
 		return resp, readJson(resp.Body, data)
@@ -269,6 +430,16 @@ func path(url string, args ...string) (link string) {
 	return
 }
 
+// Escape the segments of a link for the request url (e.g: ids of name based
+// links may contain '%' or spaces). The request is signed with the link as is.
+func escapeLink(link string) string {
+	parts := strings.Split(link, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
 // Read json response to given interface(struct, map, ..)
 func readJson(reader io.Reader, data interface{}) error {
 	return json.NewDecoder(reader).Decode(data)
@@ -2,10 +2,14 @@ package documentdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -66,19 +70,19 @@ func TestRead(t *testing.T) {
 	assert := assert.New(t)
 	s := ServerFactory(`{"_colls": "colls"}`, 500)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
 	// First call
 	var db Database
-	_, err := client.Query(ctx, "/dbs/b7NTAS==/", nil, &db)
+	_, err := client.Query(ctx, "/dbs/b7NTAS==/", nil, &db, nil)
 	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
 	assert.Equal(db.Colls, "colls", "Should fill the fields from response body")
 	assert.Nil(err, "err should be nil")
 
 	// Second Call, when StatusCode != StatusOK
-	_, err = client.Query(ctx, "/dbs/b7NCAA==/colls/Ad352/", nil, &db)
+	_, err = client.Query(ctx, "/dbs/b7NCAA==/colls/Ad352/", nil, &db, nil)
 	assert.Equal(err.Error(), "500, DocumentDB error")
 }
 
@@ -86,20 +90,20 @@ func TestQuery(t *testing.T) {
 	assert := assert.New(t)
 	s := ServerFactory(`{"_colls": "colls"}`, 500)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
 	// First call
 	var db Database
-	_, err := client.Query(ctx, "dbs", NewQuery("SELECT * FROM ROOT r", nil), &db)
+	_, err := client.Query(ctx, "dbs", NewQuery("SELECT * FROM ROOT r", nil), &db, nil)
 	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
 	s.AssertHeaders(t, HEADER_CONLEN, HEADER_CONTYPE, HEADER_IS_QUERY)
 	assert.Equal(db.Colls, "colls", "Should fill the fields from response body")
 	assert.Nil(err, "err should be nil")
 
 	// Second Call, when StatusCode != StatusOK
-	_, err = client.Query(ctx, "/dbs/b7NCAA==/colls/Ad352/", nil, &db)
+	_, err = client.Query(ctx, "/dbs/b7NCAA==/colls/Ad352/", nil, &db, nil)
 	assert.Equal(err.Error(), "500, DocumentDB error")
 }
 
@@ -108,7 +112,7 @@ func TestCreate(t *testing.T) {
 	s := ServerFactory(`{"_colls": "colls"}`, `{"id": "9"}`, 500)
 	s.SetStatus(http.StatusCreated)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
@@ -137,7 +141,7 @@ func TestDelete(t *testing.T) {
 	s := ServerFactory(`10`, 500)
 	s.SetStatus(http.StatusNoContent)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
@@ -156,7 +160,7 @@ func TestReplace(t *testing.T) {
 	s := ServerFactory(`{"_colls": "colls"}`, `{"id": "9"}`, 500)
 	s.SetStatus(http.StatusOK)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
@@ -185,13 +189,13 @@ func TestExecute(t *testing.T) {
 	s := ServerFactory(`{"_colls": "colls"}`, `{"id": "9"}`, 500)
 	s.SetStatus(http.StatusOK)
 	defer s.Close()
-	client := &Client{Url: s.URL, Config: Config{"YXJpZWwNCg=="}}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
 
 	ctx := context.Background()
 
 	// First call
 	var db Database
-	err := client.Execute(ctx, "dbs", `{"id": 3}`, &db)
+	err := client.Execute(ctx, "dbs", `{"id": 3}`, &db, nil)
 	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
 	assert.Equal(db.Colls, "colls", "Should fill the fields from response body")
 	assert.Nil(err, "err should be nil")
@@ -199,12 +203,120 @@ func TestExecute(t *testing.T) {
 	// Second call
 	var doc, tDoc Document
 	tDoc.Id = "9"
-	err = client.Execute(ctx, "dbs", tDoc, &doc)
+	err = client.Execute(ctx, "dbs", tDoc, &doc, nil)
 	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
 	assert.Equal(doc.Id, "9", "Should fill the fields from response body")
 	assert.Nil(err, "err should be nil")
 
 	// Last Call, when StatusCode != StatusOK && StatusCreated
-	err = client.Execute(ctx, "dbs", tDoc, &doc)
+	err = client.Execute(ctx, "dbs", tDoc, &doc, nil)
 	assert.Equal(err.Error(), "500, DocumentDB error")
 }
+
+// Reader that can't be rewound by the http package
+type streamReader struct {
+	io.Reader
+}
+
+func TestUpload(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(503, `{"id": "logo", "contentType": "image/png", "media": "media/abc"}`)
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", MaxRetries: 3}}
+
+	ctx := context.Background()
+	headers := map[string]string{HEADER_SLUG: "logo", HEADER_CONTYPE: "image/png"}
+
+	// Streamed bodies are not retried
+	var att Attachment
+	err := client.Upload(ctx, "dbs/b7NTAS==/colls/b7NTAP==/docs/b7NTAP==/attachments/", streamReader{strings.NewReader("png")}, &att, headers)
+	assert.NotNil(err)
+
+	err = client.Upload(ctx, "dbs/b7NTAS==/colls/b7NTAP==/docs/b7NTAP==/attachments/", streamReader{strings.NewReader("png")}, &att, headers)
+	assert.Nil(err)
+	assert.Equal("png", s.Body)
+	assert.Equal("logo", s.Header.Get(HEADER_SLUG))
+	assert.Equal("image/png", s.Header.Get(HEADER_CONTYPE))
+	assert.Equal("media/abc", att.Media)
+}
+
+func TestDownload(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory("png", 500)
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
+
+	ctx := context.Background()
+	rc, err := client.Download(ctx, "media/abc", nil)
+	assert.Nil(err)
+	b, err := ioutil.ReadAll(rc)
+	assert.Nil(err)
+	assert.Nil(rc.Close())
+	assert.Equal("png\n", string(b))
+	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
+
+	_, err = client.Download(ctx, "media/abc", nil)
+	assert.NotNil(err)
+}
+
+func TestMiddlewares(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "1"}`, 500)
+	s.SetStatus(http.StatusOK)
+	defer s.Close()
+
+	type key struct{}
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, r *Request) (*http.Response, error) {
+				calls = append(calls, name+":"+r.Method+":"+r.Link()+":"+r.ResourceType())
+				assert.Equal("value", ctx.Value(key{}))
+				assert.NotEmpty(r.Header.Get(HEADER_AUTH), "should be called with a signed request")
+				r.Header.Set("X-Middleware", name)
+				resp, err := next(ctx, r)
+				calls = append(calls, name+":"+strconv.Itoa(resp.StatusCode))
+				return resp, err
+			}
+		}
+	}
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{mw("a"), mw("b")}}}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	var doc Document
+	_, err := client.Query(ctx, "dbs/b7NTAS==/colls/b7NTAP==/docs/", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Equal("b", s.Header.Get("X-Middleware"))
+	assert.Equal([]string{"a:GET:dbs/b7NTAS==/colls/b7NTAP==/docs/:docs", "b:GET:dbs/b7NTAS==/colls/b7NTAP==/docs/:docs", "b:200", "a:200"}, calls)
+
+	calls = nil
+	_, err = client.Query(ctx, "dbs/b7NTAS==/colls/b7NTAP==/docs/", nil, &doc, nil)
+	assert.NotNil(err)
+	assert.Equal([]string{"a:GET:dbs/b7NTAS==/colls/b7NTAP==/docs/:docs", "b:GET:dbs/b7NTAS==/colls/b7NTAP==/docs/:docs", "b:500", "a:500"}, calls)
+}
+
+func TestConfigIgnoreContext(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "1"}`)
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", IgnoreContext: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var doc Document
+	_, err := client.Query(ctx, "dbs/b7NTAS==/", nil, &doc, nil)
+	assert.Nil(err, "should ignore the canceled context")
+}
+
+func TestNotFound(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"code": "NotFound", "message": "Entity with the specified id does not exist in the system."}`)
+	s.SetStatus(http.StatusNotFound)
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	var doc Document
+	err := c.ReadDocument(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc)
+	assert.True(errors.Is(err, ErrNotFound))
+	var e *RequestError
+	assert.True(errors.As(err, &e))
+	assert.Equal(http.StatusNotFound, e.StatusCode)
+}
@@ -0,0 +1,190 @@
+package documentdb
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures the client created by NewWithOptions and
+// NewFromConnectionString.
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	config  Config
+	client  *http.Client
+	timeout time.Duration
+}
+
+// WithMasterKey authorizes the requests using the account master key.
+func WithMasterKey(key string) Option {
+	return func(o *clientOptions) { o.config.MasterKey = key }
+}
+
+// WithAuthorizer signs the requests with the given Authorizer instead of
+// the master key (e.g: RotatingKey or ResourceTokens).
+func WithAuthorizer(auth Authorizer) Option {
+	return func(o *clientOptions) { o.config.Authorizer = auth }
+}
+
+// WithHTTPClient sends the requests with the given http.Client, defaults to
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) { o.client = client }
+}
+
+// WithTimeout limits the time of each attempt of a request, including
+// reading the response body. The given http.Client is not modified.
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) { o.timeout = d }
+}
+
+// WithUserAgentSuffix appends the given suffix to the "user-agent" header.
+func WithUserAgentSuffix(suffix string) Option {
+	return func(o *clientOptions) { o.config.UserAgentSuffix = suffix }
+}
+
+// WithMaxRetries sets the max retries of the default retry policy.
+func WithMaxRetries(n int) Option {
+	return func(o *clientOptions) { o.config.MaxRetries = n }
+}
+
+// WithRetryPolicy decides which failed requests are retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) { o.config.RetryPolicy = policy }
+}
+
+// WithPreferredRegions sets the regions of the account to send the requests
+// to, in order of preference.
+func WithPreferredRegions(regions ...string) Option {
+	return func(o *clientOptions) { o.config.PreferredRegions = regions }
+}
+
+// WithHedgedReads sends the point reads that aren't answered within the
+// threshold to the next preferred region as well, see Config.HedgeThreshold.
+func WithHedgedReads(threshold time.Duration) Option {
+	return func(o *clientOptions) { o.config.HedgeThreshold = threshold }
+}
+
+// WithConsistencyLevel sets the consistency of the reads.
+func WithConsistencyLevel(level ConsistencyLevel) Option {
+	return func(o *clientOptions) { o.config.ConsistencyLevel = level }
+}
+
+// WithMiddlewares intercepts the requests of the client, see Middleware.
+func WithMiddlewares(mws ...Middleware) Option {
+	return func(o *clientOptions) { o.config.Middlewares = append(o.config.Middlewares, mws...) }
+}
+
+// NewWithOptions create DocumentDBClient for the given account endpoint.
+// Unlike New, the configuration is validated up-front.
+//
+// Example:
+//
+//	client, err := NewWithOptions(
+//		"https://account.documents.azure.com",
+//		WithMasterKey(key),
+//		WithTimeout(10*time.Second),
+//	)
+func NewWithOptions(endpoint string, opts ...Option) (*DocumentDB, error) {
+	o := &clientOptions{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := validateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	client := o.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if o.timeout > 0 {
+		c := *client
+		c.Timeout = o.timeout
+		client = &c
+	}
+	return &DocumentDB{&Client{
+		Url:    strings.Trim(endpoint, "/"),
+		Config: o.config,
+		Client: client,
+	}}, nil
+}
+
+// NewFromConnectionString create DocumentDBClient from an account connection
+// string (i.e: "AccountEndpoint=https://...;AccountKey=...;"). The given
+// options override the connection string settings.
+func NewFromConnectionString(conn string, opts ...Option) (*DocumentDB, error) {
+	endpoint, key, err := parseConnectionString(conn)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithOptions(endpoint, append([]Option{WithMasterKey(key)}, opts...)...)
+}
+
+// Parse the endpoint and the key of a connection string. Keys are case
+// insensitive and unknown keys are ignored.
+func parseConnectionString(conn string) (endpoint, key string, err error) {
+	for _, part := range strings.Split(conn, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// the key may contain '=' paddings
+		i := strings.Index(part, "=")
+		if i < 0 {
+			return "", "", fmt.Errorf("invalid connection string: malformed part %q", part)
+		}
+		switch v := strings.TrimSpace(part[i+1:]); strings.ToLower(strings.TrimSpace(part[:i])) {
+		case "accountendpoint":
+			endpoint = v
+		case "accountkey":
+			key = v
+		}
+	}
+	if endpoint == "" {
+		return "", "", errors.New("invalid connection string: missing AccountEndpoint")
+	}
+	if key == "" {
+		return "", "", errors.New("invalid connection string: missing AccountKey")
+	}
+	return endpoint, key, nil
+}
+
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %v", err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: expected an absolute http(s) url", endpoint)
+	}
+	return nil
+}
+
+func (o *clientOptions) validate() error {
+	if o.config.Authorizer == nil && o.config.MasterKey == "" {
+		return errors.New("missing master key or authorizer")
+	}
+	if o.config.MasterKey != "" {
+		if _, err := base64.StdEncoding.DecodeString(o.config.MasterKey); err != nil {
+			return fmt.Errorf("invalid master key: %v", err)
+		}
+	}
+	if o.config.MaxRetries < 0 {
+		return fmt.Errorf("invalid max retries: %d", o.config.MaxRetries)
+	}
+	if o.config.HedgeThreshold < 0 {
+		return fmt.Errorf("invalid hedge threshold: %v", o.config.HedgeThreshold)
+	}
+	if o.timeout < 0 {
+		return fmt.Errorf("invalid timeout: %v", o.timeout)
+	}
+	return nil
+}
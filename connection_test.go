@@ -0,0 +1,86 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConnectionString(t *testing.T) {
+	assert := assert.New(t)
+	endpoint, key, err := parseConnectionString("AccountEndpoint=https://foo.documents.azure.com:443/;AccountKey=YXJpZWwNCg==;")
+	assert.Nil(err)
+	assert.Equal("https://foo.documents.azure.com:443/", endpoint)
+	assert.Equal("YXJpZWwNCg==", key)
+
+	// case insensitive keys, unknown keys are ignored
+	endpoint, key, err = parseConnectionString(" accountkey = YXJpZWwNCg== ; ACCOUNTENDPOINT=https://foo;Database=bar")
+	assert.Nil(err)
+	assert.Equal("https://foo", endpoint)
+	assert.Equal("YXJpZWwNCg==", key)
+
+	for _, conn := range []string{
+		"",
+		"AccountEndpoint=https://foo",
+		"AccountKey=YXJpZWwNCg==",
+		"AccountEndpoint=https://foo;AccountKey",
+	} {
+		_, _, err := parseConnectionString(conn)
+		assert.NotNil(err, conn)
+	}
+}
+
+func TestNewFromConnectionString(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "db"}`)
+	defer s.Close()
+	client, err := NewFromConnectionString(
+		"AccountEndpoint="+s.URL+"/;AccountKey=YXJpZWwNCg==",
+		WithUserAgentSuffix("my-app"),
+		WithMaxRetries(2),
+	)
+	assert.Nil(err)
+	c := client.client.(*Client)
+	assert.Equal(s.URL, c.Url)
+	assert.Equal("YXJpZWwNCg==", c.Config.MasterKey)
+	assert.Equal(2, c.Config.MaxRetries)
+	assert.Equal(http.DefaultClient, c.Client)
+
+	_, err = client.ReadDatabase(context.Background(), "dbs/b5NCAA==")
+	assert.Nil(err)
+	assert.Equal("documentdb-go my-app", s.Header.Get(HEADER_USER_AGENT))
+	s.AssertHeaders(t, HEADER_XDATE, HEADER_AUTH, HEADER_VER)
+
+	_, err = NewFromConnectionString("AccountEndpoint=" + s.URL + ";AccountKey=not base64")
+	assert.NotNil(err, "validate the master key up-front")
+}
+
+func TestNewWithOptions(t *testing.T) {
+	assert := assert.New(t)
+	auth := MasterKey("YXJpZWwNCg==")
+	httpClient := &http.Client{}
+	client, err := NewWithOptions("https://foo.documents.azure.com", WithAuthorizer(auth), WithHTTPClient(httpClient), WithTimeout(time.Second), WithPreferredRegions("West US", "East US"))
+	assert.Nil(err)
+	c := client.client.(*Client)
+	assert.Equal([]string{"West US", "East US"}, c.Config.PreferredRegions)
+	assert.Equal(auth, c.Config.Authorizer)
+	assert.Equal(time.Second, c.Client.Timeout)
+	assert.Equal(time.Duration(0), httpClient.Timeout, "the given client is not modified")
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithMasterKey("not base64")},
+		{WithMasterKey("YXJpZWwNCg=="), WithMaxRetries(-1)},
+		{WithMasterKey("YXJpZWwNCg=="), WithTimeout(-time.Second)},
+	} {
+		_, err := NewWithOptions("https://foo.documents.azure.com", opts...)
+		assert.NotNil(err)
+	}
+	for _, endpoint := range []string{"", "foo.documents.azure.com", "ftp://foo", "https://"} {
+		_, err := NewWithOptions(endpoint, WithMasterKey("YXJpZWwNCg=="))
+		assert.NotNil(err, endpoint)
+	}
+}
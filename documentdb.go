@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 )
 
 var (
@@ -20,10 +21,7 @@ var (
 )
 
 func IsExists(err error) bool {
-	if e, ok := err.(*RequestError); ok && e.Code == "Conflict" {
-		return true
-	}
-	return false
+	return errors.Is(err, ErrConflict)
 }
 
 // Id setter
@@ -38,6 +36,34 @@ func Doc(id string) Document {
 type Config struct {
 	MasterKey  string
 	MaxRetries int
+	// Authorizer signs the requests, defaults to the MasterKey.
+	Authorizer Authorizer
+	// ConsistencyLevel of the reads, defaults to the account consistency.
+	ConsistencyLevel ConsistencyLevel
+	// RetryPolicy decides which failed requests are retried, defaults to
+	// DefaultRetryPolicy with MaxRetries.
+	RetryPolicy RetryPolicy
+	// Middlewares intercept the requests of the client, see Middleware.
+	Middlewares []Middleware
+	// IgnoreContext sends the requests without the operation context,
+	// the context is still given to the middlewares.
+	IgnoreContext bool
+	// UserAgentSuffix is appended to the "user-agent" header of the requests
+	// (e.g: the name of the application).
+	UserAgentSuffix string
+	// PreferredRegions are the regions of the account to send the reads to,
+	// in order of preference (e.g: "West US", "East US"). When it's given,
+	// the account locations are discovered and the writes are sent to the
+	// write region. Unreachable regions are skipped.
+	PreferredRegions []string
+	// HedgeThreshold enables the hedged point reads (i.e: ReadDocument). A
+	// read that isn't answered within the threshold is sent to the next
+	// preferred region as well, and the first success wins. The charge of
+	// both regions is counted in ResponseInfo. It requires PreferredRegions.
+	HedgeThreshold time.Duration
+	// EndpointRefresh is the interval of the account locations refresh,
+	// defaults to 5 minutes.
+	EndpointRefresh time.Duration
 }
 
 type DocumentDB struct {
@@ -54,6 +80,12 @@ func New(url string, config Config) *DocumentDB {
 	return &DocumentDB{client}
 }
 
+// NewWithClient create DocumentDBClient that sends its requests with the
+// given Clienter (e.g: a Client wrapped with instrumentation)
+func NewWithClient(client Clienter) *DocumentDB {
+	return &DocumentDB{client}
+}
+
 func IdQuery(id string) *Query {
 	return &Query{
 		Text:   "SELECT * FROM ROOT r WHERE r.id = @id",
@@ -61,32 +93,35 @@ func IdQuery(id string) *Query {
 	}
 }
 
-func (c *DocumentDB) CreateDB(ctx context.Context, id string) (*DB, error) {
-	d, err := c.CreateDatabase(ctx, map[string]string{"id": id})
+func (c *DocumentDB) CreateDB(ctx context.Context, id string, opts ...*RequestOptions) (*DB, error) {
+	d, err := c.CreateDatabase(ctx, map[string]string{"id": id}, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return &DB{c: c, Database: *d}, nil
 }
 
-func (c *DocumentDB) CreateDBIfNotExists(ctx context.Context, id string) (*DB, error) {
+// CreateDBIfNotExists read the database, or create it with the given options
+// if it doesn't exist.
+func (c *DocumentDB) CreateDBIfNotExists(ctx context.Context, id string, opts ...*RequestOptions) (*DB, error) {
 	db, err := c.DB(ctx, id)
-	if err == ErrNotFound {
-		if db, err = c.CreateDB(ctx, id); IsExists(err) {
+	if errors.Is(err, ErrNotFound) {
+		if db, err = c.CreateDB(ctx, id, opts...); IsExists(err) {
 			db, err = c.DB(ctx, id)
 		}
 	}
 	return db, err
 }
 
-func (c *DocumentDB) DB(ctx context.Context, id string) (*DB, error) {
-	dbs, err := c.QueryDatabases(ctx, IdQuery(id))
+// DB read database by id
+func (c *DocumentDB) DB(ctx context.Context, id string, opts ...*RequestOptions) (*DB, error) {
+	db, err := c.ReadDatabase(ctx, "dbs/"+id, opts...)
 	if err != nil {
 		return nil, err
-	} else if len(dbs) == 0 {
+	} else if db == nil {
 		return nil, ErrNotFound
 	}
-	return &DB{c: c, Database: dbs[0]}, nil
+	return &DB{c: c, Database: *db}, nil
 }
 
 type DB struct {
@@ -94,40 +129,46 @@ type DB struct {
 	Database
 }
 
-func (db *DB) Delete(ctx context.Context) error {
-	return db.c.DeleteDatabase(ctx, db.Self)
+func (db *DB) Delete(ctx context.Context, opts ...*RequestOptions) error {
+	return db.c.DeleteDatabase(ctx, db.Self, opts...)
 }
 
-func (db *DB) CreateCollection(ctx context.Context, id string, col *Collection) (*Col, error) {
+func (db *DB) CreateCollection(ctx context.Context, id string, col *Collection, opts ...*RequestOptions) (*Col, error) {
 	if col == nil {
 		col = &Collection{}
 	}
 	col.Id = id
-	c, err := db.c.CreateCollection(ctx, db.Self, col)
+	if pk := col.PartitionKey; pk != nil && pk.Kind == "" {
+		pk.Kind = HashPartition
+	}
+	c, err := db.c.CreateCollection(ctx, db.Self, col, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return &Col{db: db, Collection: *c}, nil
 }
 
-func (db *DB) CreateCollectionIfNotExists(ctx context.Context, id string, col *Collection) (*Col, error) {
+// CreateCollectionIfNotExists read the collection, or create it with the given
+// options if it doesn't exist.
+func (db *DB) CreateCollectionIfNotExists(ctx context.Context, id string, col *Collection, opts ...*RequestOptions) (*Col, error) {
 	c, err := db.C(ctx, id)
-	if err == ErrNotFound {
-		if c, err = db.CreateCollection(ctx, id, col); IsExists(err) {
+	if errors.Is(err, ErrNotFound) {
+		if c, err = db.CreateCollection(ctx, id, col, opts...); IsExists(err) {
 			c, err = db.C(ctx, id)
 		}
 	}
 	return c, err
 }
 
-func (db *DB) C(ctx context.Context, id string) (*Col, error) {
-	colls, err := db.c.QueryCollections(ctx, db.Self, IdQuery(id))
+// C read collection by id
+func (db *DB) C(ctx context.Context, id string, opts ...*RequestOptions) (*Col, error) {
+	coll, err := db.c.ReadCollection(ctx, "dbs/"+db.Id+"/colls/"+id, opts...)
 	if err != nil {
 		return nil, err
-	} else if len(colls) == 0 {
+	} else if coll == nil {
 		return nil, ErrNotFound
 	}
-	return &Col{db: db, Collection: colls[0]}, nil
+	return &Col{db: db, Collection: *coll}, nil
 }
 
 type Col struct {
@@ -136,49 +177,148 @@ type Col struct {
 }
 
 func (c *Col) ctx(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, selfKey{}, collectionLink(c.Self))
 	return context.WithValue(ctx, collKey{}, string(c.Collection.Id))
 }
-func (c *Col) Delete(ctx context.Context) error {
-	return c.db.c.DeleteCollection(c.ctx(ctx), c.Self)
+
+// Return the name based link of the collection (e.g: "dbs/db/colls/coll/")
+func (c *Col) link() string {
+	return "dbs/" + c.db.Id + "/colls/" + c.Id + "/"
+}
+func (c *Col) Delete(ctx context.Context, opts ...*RequestOptions) error {
+	return c.db.c.DeleteCollection(c.ctx(ctx), c.Self, opts...)
+}
+
+// Return the options of a document operation, with the partition key
+// extracted from the document when it's not given explicitly.
+func (c *Col) docOptions(doc interface{}, opts []*RequestOptions) (*RequestOptions, error) {
+	o := requestOptions(opts)
+	if c.PartitionKey == nil || (o != nil && o.PartitionKey != nil) {
+		return o, nil
+	}
+	pk, err := partitionKeyOf(doc, c.PartitionKey)
+	if err != nil {
+		return nil, err
+	}
+	var po RequestOptions
+	if o != nil {
+		po = *o
+	}
+	po.PartitionKey = pk
+	return &po, nil
+}
+
+func (c *Col) QueryDocuments(ctx context.Context, qu *Query, out interface{}, opts ...*RequestOptions) (string, error) {
+	return c.db.c.QueryDocuments(c.ctx(ctx), c.Self, qu, out, opts...)
 }
 
-func (c *Col) QueryDocuments(ctx context.Context, qu *Query, out interface{}) (string, error) {
-	return c.db.c.QueryDocuments(c.ctx(ctx), c.Self, qu, out)
+// QueryDocumentsCrossPartition run the query over all the collection
+// partitions and decode the merged results into out.
+func (c *Col) QueryDocumentsCrossPartition(ctx context.Context, qu *Query, out interface{}, opts ...*RequestOptions) error {
+	return c.db.c.QueryDocumentsCrossPartition(c.ctx(ctx), c.Self, qu, out, opts...)
 }
 
-func (c *Col) CreateDocument(ctx context.Context, doc interface{}) (*Document, error) {
-	return c.db.c.CreateDocument(c.ctx(ctx), c.Self, doc)
+// IterateDocuments returns an iterator over the documents that satisfy the
+// query, or over all the collection documents if the query is nil.
+func (c *Col) IterateDocuments(qu *Query, opts IteratorOptions, reqOpts ...*RequestOptions) *DocumentIterator {
+	return newDocumentIterator(func(ctx context.Context, qu *Query, out interface{}) (string, error) {
+		return c.db.c.QueryDocuments(c.ctx(ctx), c.Self, qu, out, reqOpts...)
+	}, qu, opts)
 }
 
-func (c *Col) UpdateDocument(ctx context.Context, doc interface{}, etag string) (*Document, error) {
-	return c.db.c.UpdateDocument(c.ctx(ctx), c.Self, doc, etag)
+// ReadDocument read document by id. The partition key of the document must
+// be given on partitioned collections.
+func (c *Col) ReadDocument(ctx context.Context, id string, doc interface{}, opts ...*RequestOptions) error {
+	return c.db.c.ReadDocument(c.ctx(ctx), c.link()+"docs/"+id, doc, opts...)
 }
 
-func (c *Col) UpsertDocument(ctx context.Context, doc interface{}, etag string) (*Document, error) {
-	return c.db.c.UpsertDocument(c.ctx(ctx), c.Self, doc, etag)
+// ReadDocumentByLink read document by self link. The partition key of the
+// document must be given on partitioned collections.
+func (c *Col) ReadDocumentByLink(ctx context.Context, link string, doc interface{}, opts ...*RequestOptions) error {
+	return c.db.c.ReadDocument(c.ctx(ctx), link, doc, opts...)
 }
 
-func (c *Col) DeleteDocumentByLink(ctx context.Context, link string, etag string) error {
-	return c.db.c.DeleteDocument(c.ctx(ctx), link, etag)
+// ChangeFeed returns an iterator over the changed documents of the collection
+func (c *Col) ChangeFeed(ctx context.Context, opts ChangeFeedOptions) (*ChangeFeedIterator, error) {
+	return c.db.c.changeFeed(ctx, c.Self, opts, c.ctx)
 }
 
-func (c *Col) CreateProc(ctx context.Context, id, fnc string) (*Proc, error) {
+func (c *Col) CreateDocument(ctx context.Context, doc interface{}, opts ...*RequestOptions) (*Document, error) {
+	setId(doc)
+	o, err := c.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.db.c.CreateDocument(c.ctx(ctx), c.Self, doc, o)
+}
+
+func (c *Col) UpdateDocument(ctx context.Context, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
+	o, err := c.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.db.c.UpdateDocument(c.ctx(ctx), c.link(), doc, etag, o)
+}
+
+func (c *Col) UpsertDocument(ctx context.Context, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
+	setId(doc)
+	o, err := c.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.db.c.UpsertDocument(c.ctx(ctx), c.Self, doc, etag, o)
+}
+
+// ReplaceDocument replace document by id. If etag is given, the document is
+// replaced only if it wasn't changed since.
+func (c *Col) ReplaceDocument(ctx context.Context, id string, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
+	return c.ReplaceDocumentByLink(ctx, c.link()+"docs/"+id, doc, etag, opts...)
+}
+
+// ReplaceDocumentByLink replace document by self link. If etag is given, the
+// document is replaced only if it wasn't changed since.
+func (c *Col) ReplaceDocumentByLink(ctx context.Context, link string, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
+	o, err := c.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string)
+	if etag != "" {
+		headers[HEADER_IF_MATCH] = etag
+	}
+	return c.db.c.ReplaceDocument(c.ctx(ctx), link, doc, headers, o)
+}
+
+// DeleteDocument delete document by id. The partition key of the document
+// must be given on partitioned collections.
+func (c *Col) DeleteDocument(ctx context.Context, id string, etag string, opts ...*RequestOptions) error {
+	return c.DeleteDocumentByLink(ctx, c.link()+"docs/"+id, etag, opts...)
+}
+
+// DeleteDocumentByLink delete document by self link. The partition key of the
+// document must be given on partitioned collections.
+func (c *Col) DeleteDocumentByLink(ctx context.Context, link string, etag string, opts ...*RequestOptions) error {
+	return c.db.c.DeleteDocument(c.ctx(ctx), link, etag, opts...)
+}
+
+func (c *Col) CreateProc(ctx context.Context, id, fnc string, opts ...*RequestOptions) (*Proc, error) {
 	p := &Proc{c: c, Sproc: Sproc{Body: fnc}}
 	p.Id = id
-	if err := c.db.c.CreateStoredProcedure(c.ctx(ctx), c.Self, &p.Sproc); err != nil {
+	if err := c.db.c.CreateStoredProcedure(c.ctx(ctx), c.Self, &p.Sproc, opts...); err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-func (c *Col) Proc(ctx context.Context, id string) (*Proc, error) {
-	procs, err := c.db.c.QueryStoredProcedures(c.ctx(ctx), c.Self, IdQuery(id))
+// Proc read stored procedure by id
+func (c *Col) Proc(ctx context.Context, id string, opts ...*RequestOptions) (*Proc, error) {
+	sproc, err := c.db.c.ReadStoredProcedure(c.ctx(ctx), c.link()+"sprocs/"+id, opts...)
 	if err != nil {
 		return nil, err
-	} else if len(procs) == 0 {
+	} else if sproc == nil {
 		return nil, ErrNotFound
 	}
-	return &Proc{c: c, Sproc: procs[0]}, nil
+	return &Proc{c: c, Sproc: *sproc}, nil
 }
 
 type Proc struct {
@@ -187,18 +327,27 @@ type Proc struct {
 }
 
 func (p *Proc) Execute(ctx context.Context, out interface{}, args ...interface{}) error {
+	return p.ExecuteWithOptions(ctx, nil, out, args...)
+}
+
+// ExecuteWithOptions execute the stored procedure with the given request
+// options. On partitioned collections, the partition key must be given.
+func (p *Proc) ExecuteWithOptions(ctx context.Context, opts *RequestOptions, out interface{}, args ...interface{}) error {
 	var params interface{}
 	if len(args) != 0 {
 		params = args
 	}
 	ctx = context.WithValue(ctx, sprocKey{}, string(p.Id))
-	return p.c.db.c.ExecuteStoredProcedure(ctx, p.Self, params, out)
+	return p.c.db.c.ExecuteStoredProcedure(ctx, p.Self, params, out, opts)
 }
 
-// TODO: Add `requestOptions` arguments
 // Read database by self link
-func (c *DocumentDB) ReadDatabase(ctx context.Context, link string) (db *Database, err error) {
-	_, err = c.client.Query(ctx, link, nil, &db)
+func (c *DocumentDB) ReadDatabase(ctx context.Context, link string, opts ...*RequestOptions) (db *Database, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &db, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -206,23 +355,37 @@ func (c *DocumentDB) ReadDatabase(ctx context.Context, link string) (db *Databas
 }
 
 // Read collection by self link
-func (c *DocumentDB) ReadCollection(ctx context.Context, link string) (coll *Collection, err error) {
-	_, err = c.client.Query(ctx, link, nil, &coll)
+func (c *DocumentDB) ReadCollection(ctx context.Context, link string, opts ...*RequestOptions) (coll *Collection, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &coll, headers)
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-// Read document by self link
-func (c *DocumentDB) ReadDocument(ctx context.Context, link string, doc interface{}) (err error) {
-	_, err = c.client.Query(ctx, link, nil, &doc)
+// Read document by self link. The read may be sent to several regions, see
+// Config.HedgeThreshold.
+func (c *DocumentDB) ReadDocument(ctx context.Context, link string, doc interface{}, opts ...*RequestOptions) (err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, hedgeKey{}, true)
+	_, err = c.client.Query(ctx, link, nil, &doc, headers)
 	return
 }
 
 // Read sporc by self link
-func (c *DocumentDB) ReadStoredProcedure(ctx context.Context, link string) (sproc *Sproc, err error) {
-	_, err = c.client.Query(ctx, link, nil, &sproc)
+func (c *DocumentDB) ReadStoredProcedure(ctx context.Context, link string, opts ...*RequestOptions) (sproc *Sproc, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &sproc, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -230,8 +393,12 @@ func (c *DocumentDB) ReadStoredProcedure(ctx context.Context, link string) (spro
 }
 
 // Read udf by self link
-func (c *DocumentDB) ReadUserDefinedFunction(ctx context.Context, link string) (udf *UDF, err error) {
-	_, err = c.client.Query(ctx, link, nil, &udf)
+func (c *DocumentDB) ReadUserDefinedFunction(ctx context.Context, link string, opts ...*RequestOptions) (udf *UDF, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &udf, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -239,42 +406,46 @@ func (c *DocumentDB) ReadUserDefinedFunction(ctx context.Context, link string) (
 }
 
 // Read all databases
-func (c *DocumentDB) ReadDatabases(ctx context.Context) (dbs []Database, err error) {
-	return c.QueryDatabases(ctx, nil)
+func (c *DocumentDB) ReadDatabases(ctx context.Context, opts ...*RequestOptions) (dbs []Database, err error) {
+	return c.QueryDatabases(ctx, nil, opts...)
 }
 
 // Read all collections by db selflink
-func (c *DocumentDB) ReadCollections(ctx context.Context, db string) (colls []Collection, err error) {
-	return c.QueryCollections(ctx, db, nil)
+func (c *DocumentDB) ReadCollections(ctx context.Context, db string, opts ...*RequestOptions) (colls []Collection, err error) {
+	return c.QueryCollections(ctx, db, nil, opts...)
 }
 
 // Read all sprocs by collection self link
-func (c *DocumentDB) ReadStoredProcedures(ctx context.Context, coll string) (sprocs []Sproc, err error) {
-	return c.QueryStoredProcedures(ctx, coll, nil)
+func (c *DocumentDB) ReadStoredProcedures(ctx context.Context, coll string, opts ...*RequestOptions) (sprocs []Sproc, err error) {
+	return c.QueryStoredProcedures(ctx, coll, nil, opts...)
 }
 
 // Read all udfs by collection self link
-func (c *DocumentDB) ReadUserDefinedFunctions(ctx context.Context, coll string) (udfs []UDF, err error) {
-	return c.QueryUserDefinedFunctions(ctx, coll, nil)
+func (c *DocumentDB) ReadUserDefinedFunctions(ctx context.Context, coll string, opts ...*RequestOptions) (udfs []UDF, err error) {
+	return c.QueryUserDefinedFunctions(ctx, coll, nil, opts...)
 }
 
-// Read all collection documents by self link
-// TODO: use iterator for heavy transactions
-func (c *DocumentDB) ReadDocuments(ctx context.Context, coll string, ctoken string, docs interface{}) (token string, err error) {
+// Read all collection documents by self link.
+// Use IterateDocuments to walk over big collections
+func (c *DocumentDB) ReadDocuments(ctx context.Context, coll string, ctoken string, docs interface{}, opts ...*RequestOptions) (token string, err error) {
 	var q *Query
 	if ctoken != "" {
 		q = &Query{Token: ctoken}
 	}
-	return c.QueryDocuments(ctx, coll, q, docs)
+	return c.QueryDocuments(ctx, coll, q, docs, opts...)
 }
 
 // Read all databases that satisfy a query
-func (c *DocumentDB) QueryDatabases(ctx context.Context, query *Query) (dbs []Database, err error) {
+func (c *DocumentDB) QueryDatabases(ctx context.Context, query *Query, opts ...*RequestOptions) (dbs []Database, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
 	var data struct {
 		Databases []Database `json:"Databases,omitempty"`
 		Count     int        `json:"_count,omitempty"`
 	}
-	_, err = c.client.Query(ctx, "dbs", query, &data)
+	_, err = c.client.Query(ctx, "dbs", query, &data, headers)
 	if dbs = data.Databases; err != nil {
 		dbs = nil
 	}
@@ -282,12 +453,16 @@ func (c *DocumentDB) QueryDatabases(ctx context.Context, query *Query) (dbs []Da
 }
 
 // Read all db-collection that satisfy a query
-func (c *DocumentDB) QueryCollections(ctx context.Context, db string, query *Query) (colls []Collection, err error) {
+func (c *DocumentDB) QueryCollections(ctx context.Context, db string, query *Query, opts ...*RequestOptions) (colls []Collection, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
 	var data struct {
 		Collections []Collection `json:"DocumentCollections,omitempty"`
 		Count       int          `json:"_count,omitempty"`
 	}
-	_, err = c.client.Query(ctx, db+"colls/", query, &data)
+	_, err = c.client.Query(ctx, db+"colls/", query, &data, headers)
 	if colls = data.Collections; err != nil {
 		colls = nil
 	}
@@ -295,12 +470,16 @@ func (c *DocumentDB) QueryCollections(ctx context.Context, db string, query *Que
 }
 
 // Read all collection `sprocs` that satisfy a query
-func (c *DocumentDB) QueryStoredProcedures(ctx context.Context, coll string, query *Query) (sprocs []Sproc, err error) {
+func (c *DocumentDB) QueryStoredProcedures(ctx context.Context, coll string, query *Query, opts ...*RequestOptions) (sprocs []Sproc, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
 	var data struct {
 		Sprocs []Sproc `json:"StoredProcedures,omitempty"`
 		Count  int     `json:"_count,omitempty"`
 	}
-	_, err = c.client.Query(ctx, coll+"sprocs/", query, &data)
+	_, err = c.client.Query(ctx, coll+"sprocs/", query, &data, headers)
 	if sprocs = data.Sprocs; err != nil {
 		sprocs = nil
 	}
@@ -308,12 +487,16 @@ func (c *DocumentDB) QueryStoredProcedures(ctx context.Context, coll string, que
 }
 
 // Read all collection `udfs` that satisfy a query
-func (c *DocumentDB) QueryUserDefinedFunctions(ctx context.Context, coll string, query *Query) (udfs []UDF, err error) {
+func (c *DocumentDB) QueryUserDefinedFunctions(ctx context.Context, coll string, query *Query, opts ...*RequestOptions) (udfs []UDF, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
 	var data struct {
 		Udfs  []UDF `json:"UserDefinedFunctions,omitempty"`
 		Count int   `json:"_count,omitempty"`
 	}
-	_, err = c.client.Query(ctx, coll+"udfs/", query, &data)
+	_, err = c.client.Query(ctx, coll+"udfs/", query, &data, headers)
 	if udfs = data.Udfs; err != nil {
 		udfs = nil
 	}
@@ -321,17 +504,36 @@ func (c *DocumentDB) QueryUserDefinedFunctions(ctx context.Context, coll string,
 }
 
 // Read all documents in a collection that satisfy a query
-func (c *DocumentDB) QueryDocuments(ctx context.Context, coll string, query *Query, docs interface{}) (token string, err error) {
+func (c *DocumentDB) QueryDocuments(ctx context.Context, coll string, query *Query, docs interface{}, opts ...*RequestOptions) (token string, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return "", err
+	}
+	return c.queryDocuments(ctx, coll, query, docs, headers)
+}
+
+func (c *DocumentDB) queryDocuments(ctx context.Context, coll string, query *Query, docs interface{}, headers map[string]string) (token string, err error) {
 	data := struct {
 		Documents interface{} `json:"Documents,omitempty"`
 		Count     int         `json:"_count,omitempty"`
 	}{Documents: docs}
-	return c.client.Query(ctx, coll+"docs/", query, &data)
+	return c.client.Query(ctx, coll+"docs/", query, &data, headers)
+}
+
+// Iterate over all documents in a collection that satisfy a query
+func (c *DocumentDB) IterateDocuments(coll string, query *Query, opts IteratorOptions, reqOpts ...*RequestOptions) *DocumentIterator {
+	return newDocumentIterator(func(ctx context.Context, qu *Query, out interface{}) (string, error) {
+		return c.QueryDocuments(ctx, coll, qu, out, reqOpts...)
+	}, query, opts)
 }
 
 // Create database
-func (c *DocumentDB) CreateDatabase(ctx context.Context, body interface{}) (db *Database, err error) {
-	err = c.client.Create(ctx, "dbs", body, &db, nil)
+func (c *DocumentDB) CreateDatabase(ctx context.Context, body interface{}, opts ...*RequestOptions) (db *Database, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, "dbs", body, &db, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -339,8 +541,12 @@ func (c *DocumentDB) CreateDatabase(ctx context.Context, body interface{}) (db *
 }
 
 // Create collection
-func (c *DocumentDB) CreateCollection(ctx context.Context, db string, body interface{}) (coll *Collection, err error) {
-	err = c.client.Create(ctx, db+"colls/", body, &coll, nil)
+func (c *DocumentDB) CreateCollection(ctx context.Context, db string, body interface{}, opts ...*RequestOptions) (coll *Collection, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, db+"colls/", body, &coll, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -348,27 +554,47 @@ func (c *DocumentDB) CreateCollection(ctx context.Context, db string, body inter
 }
 
 // Create stored procedure
-func (c *DocumentDB) CreateStoredProcedure(ctx context.Context, coll string, sproc *Sproc) error {
-	return c.client.Create(ctx, coll+"sprocs/", sproc, sproc, nil)
+func (c *DocumentDB) CreateStoredProcedure(ctx context.Context, coll string, sproc *Sproc, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Create(ctx, coll+"sprocs/", sproc, sproc, headers)
 }
 
 // Create user defined function
-func (c *DocumentDB) CreateUserDefinedFunction(ctx context.Context, coll string, body interface{}) (udf *UDF, err error) {
-	err = c.client.Create(ctx, coll+"udfs/", body, &udf, nil)
+func (c *DocumentDB) CreateUserDefinedFunction(ctx context.Context, coll string, body interface{}, opts ...*RequestOptions) (udf *UDF, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, coll+"udfs/", body, &udf, headers)
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-func (c *DocumentDB) createDocument(ctx context.Context, coll string, doc interface{}, headers map[string]string) (*Document, error) {
+// Generate random document id if it's missing
+func setId(doc interface{}) {
 	rv := reflect.ValueOf(doc)
 	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 	}
-	if id := rv.FieldByName("Id"); id.IsValid() && id.String() == "" {
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	if id := rv.FieldByName("Id"); id.IsValid() && id.CanSet() && id.String() == "" {
 		id.SetString(uuid())
 	}
+}
+
+func (c *DocumentDB) createDocument(ctx context.Context, coll string, doc interface{}, headers map[string]string, opts []*RequestOptions) (*Document, error) {
+	setId(doc)
+	headers, err := optionsHeaders(headers, opts)
+	if err != nil {
+		return nil, err
+	}
 	var document Document
 	if err := c.client.Create(ctx, coll+"docs/", doc, &document, headers); err != nil {
 		return nil, err
@@ -377,11 +603,14 @@ func (c *DocumentDB) createDocument(ctx context.Context, coll string, doc interf
 }
 
 // Create document
-func (c *DocumentDB) CreateDocument(ctx context.Context, coll string, doc interface{}) (*Document, error) {
-	return c.createDocument(ctx, coll, doc, nil)
+func (c *DocumentDB) CreateDocument(ctx context.Context, coll string, doc interface{}, opts ...*RequestOptions) (*Document, error) {
+	return c.createDocument(ctx, coll, doc, nil, opts)
 }
 
-func (c *DocumentDB) UpdateDocument(ctx context.Context, coll string, doc interface{}, etag string) (*Document, error) {
+// UpdateDocument replace document by its id. With a name based collection
+// link (e.g: "dbs/db/colls/coll/"), the document is addressed directly by id,
+// otherwise it's looked up by a query.
+func (c *DocumentDB) UpdateDocument(ctx context.Context, coll string, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
 	rv := reflect.ValueOf(doc)
 	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
@@ -395,66 +624,100 @@ func (c *DocumentDB) UpdateDocument(ctx context.Context, coll string, doc interf
 		}
 	}
 
+	headers := make(map[string]string)
+	if etag != "" {
+		headers[HEADER_IF_MATCH] = etag
+	}
+	// documents are addressed by id in name based collection links
+	if nameBased(coll) {
+		return c.ReplaceDocument(ctx, strings.TrimSuffix(coll, "/")+"/docs/"+id.String(), doc, headers, opts...)
+	}
+
+	// look up the document in its partition, the other options are of the
+	// replace request
+	var qopts *RequestOptions
+	if o := requestOptions(opts); o != nil {
+		qopts = &RequestOptions{PartitionKey: o.PartitionKey, ConsistencyLevel: o.ConsistencyLevel, SessionToken: o.SessionToken}
+	}
 	var docs []Document
-	_, err := c.QueryDocuments(ctx, coll, IdQuery(id.String()), &docs)
+	_, err := c.QueryDocuments(ctx, coll, IdQuery(id.String()), &docs, qopts)
 	if err != nil {
 		return nil, err
 	}
 	if len(docs) == 0 {
 		return nil, ErrNotFound
 	}
-
-	headers := make(map[string]string)
-	if etag != "" {
-		headers[HEADER_IF_MATCH] = etag
-	}
-	return c.ReplaceDocument(ctx, docs[0].Self, doc, headers)
+	return c.ReplaceDocument(ctx, docs[0].Self, doc, headers, opts...)
 }
 
 // Create document
-func (c *DocumentDB) UpsertDocument(ctx context.Context, coll string, doc interface{}, etag string) (*Document, error) {
+func (c *DocumentDB) UpsertDocument(ctx context.Context, coll string, doc interface{}, etag string, opts ...*RequestOptions) (*Document, error) {
 	headers := map[string]string{
 		HEADER_UPSERT: "true",
 	}
 	if etag != "" {
 		headers[HEADER_IF_MATCH] = etag
 	}
-	return c.createDocument(ctx, coll, doc, headers)
+	return c.createDocument(ctx, coll, doc, headers, opts)
 }
 
 // TODO: DRY, but the sdk want that[mm.. maybe just client.Delete(self_link)]
 // Delete database
-func (c *DocumentDB) DeleteDatabase(ctx context.Context, link string) error {
-	return c.client.Delete(ctx, link, nil)
+func (c *DocumentDB) DeleteDatabase(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
 }
 
 // Delete collection
-func (c *DocumentDB) DeleteCollection(ctx context.Context, link string) error {
-	return c.client.Delete(ctx, link, nil)
+func (c *DocumentDB) DeleteCollection(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
 }
 
 // Delete collection
-func (c *DocumentDB) DeleteDocument(ctx context.Context, link string, etag string) error {
+func (c *DocumentDB) DeleteDocument(ctx context.Context, link string, etag string, opts ...*RequestOptions) error {
 	headers := make(map[string]string, 0)
 	if etag != "" {
 		headers[HEADER_IF_MATCH] = etag
 	}
+	headers, err := optionsHeaders(headers, opts)
+	if err != nil {
+		return err
+	}
 	return c.client.Delete(ctx, link, headers)
 }
 
 // Delete stored procedure
-func (c *DocumentDB) DeleteStoredProcedure(ctx context.Context, link string) error {
-	return c.client.Delete(ctx, link, nil)
+func (c *DocumentDB) DeleteStoredProcedure(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
 }
 
 // Delete user defined function
-func (c *DocumentDB) DeleteUserDefinedFunction(ctx context.Context, link string) error {
-	return c.client.Delete(ctx, link, nil)
+func (c *DocumentDB) DeleteUserDefinedFunction(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
 }
 
 // Replace database
-func (c *DocumentDB) ReplaceDatabase(ctx context.Context, link string, body interface{}) (db *Database, err error) {
-	err = c.client.Replace(ctx, link, body, &db, nil)
+func (c *DocumentDB) ReplaceDatabase(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (db *Database, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &db, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -462,17 +725,25 @@ func (c *DocumentDB) ReplaceDatabase(ctx context.Context, link string, body inte
 }
 
 // Replace document
-func (c *DocumentDB) ReplaceDocument(ctx context.Context, link string, doc interface{}, headers map[string]string) (*Document, error) {
+func (c *DocumentDB) ReplaceDocument(ctx context.Context, link string, doc interface{}, headers map[string]string, opts ...*RequestOptions) (*Document, error) {
+	headers, err := optionsHeaders(headers, opts)
+	if err != nil {
+		return nil, err
+	}
 	var document Document
-	if err := c.client.Replace(ctx, link, doc, &doc, headers); err != nil {
+	if err := c.client.Replace(ctx, link, doc, &document, headers); err != nil {
 		return nil, err
 	}
 	return &document, nil
 }
 
 // Replace stored procedure
-func (c *DocumentDB) ReplaceStoredProcedure(ctx context.Context, link string, body interface{}) (sproc *Sproc, err error) {
-	err = c.client.Replace(ctx, link, body, &sproc, nil)
+func (c *DocumentDB) ReplaceStoredProcedure(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (sproc *Sproc, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &sproc, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -480,8 +751,12 @@ func (c *DocumentDB) ReplaceStoredProcedure(ctx context.Context, link string, bo
 }
 
 // Replace stored procedure
-func (c *DocumentDB) ReplaceUserDefinedFunction(ctx context.Context, link string, body interface{}) (udf *UDF, err error) {
-	err = c.client.Replace(ctx, link, body, &udf, nil)
+func (c *DocumentDB) ReplaceUserDefinedFunction(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (udf *UDF, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &udf, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -489,7 +764,11 @@ func (c *DocumentDB) ReplaceUserDefinedFunction(ctx context.Context, link string
 }
 
 // Execute stored procedure
-func (c *DocumentDB) ExecuteStoredProcedure(ctx context.Context, link string, params, body interface{}) (err error) {
-	err = c.client.Execute(ctx, link, params, body)
+func (c *DocumentDB) ExecuteStoredProcedure(ctx context.Context, link string, params, body interface{}, opts ...*RequestOptions) (err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	err = c.client.Execute(ctx, link, params, body, headers)
 	return
 }
@@ -2,6 +2,12 @@ package documentdb
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +18,7 @@ type ClientStub struct {
 	mock.Mock
 }
 
-func (c *ClientStub) Query(ctx context.Context, link string, query *Query, ret interface{}) (string, error) {
+func (c *ClientStub) Query(ctx context.Context, link string, query *Query, ret interface{}, headers map[string]string) (string, error) {
 	c.Called(link, query)
 	return "", nil
 }
@@ -32,14 +38,24 @@ func (c *ClientStub) Replace(ctx context.Context, link string, body, ret interfa
 	return nil
 }
 
-func (c *ClientStub) Execute(ctx context.Context, link string, body, ret interface{}) error {
+func (c *ClientStub) Execute(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error {
 	c.Called(link, body)
 	return nil
 }
 
+func (c *ClientStub) Upload(ctx context.Context, link string, media io.Reader, ret interface{}, headers map[string]string) error {
+	c.Called(link, headers)
+	return nil
+}
+
+func (c *ClientStub) Download(ctx context.Context, link string, headers map[string]string) (io.ReadCloser, error) {
+	c.Called(link)
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
 func TestNew(t *testing.T) {
 	assert := assert.New(t)
-	client := New("url", Config{"config"})
+	client := New("url", Config{MasterKey: "config"})
 	assert.IsType(client, &DocumentDB{}, "Should return DocumentDB object")
 }
 
@@ -310,3 +326,73 @@ func TestExecuteStoredProcedure(t *testing.T) {
 	c.ExecuteStoredProcedure(ctx, "sproc_link", "{}", struct{}{})
 	client.AssertCalled(t, "Execute", "sproc_link", "{}")
 }
+
+func TestNameBasedAddressing(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	db := &DB{c: c, Database: Database{Resource: Resource{Id: "db", Self: "dbs/b5NCAA==/"}}}
+	coll := &Col{db: db, Collection: Collection{Resource: Resource{Id: "coll", Self: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"}}}
+	client.On("Query", mock.Anything, (*Query)(nil)).Return("", nil)
+	client.On("Replace", "dbs/db/colls/coll/docs/foo", mock.Anything).Return(nil)
+	client.On("Delete", "dbs/db/colls/coll/docs/foo").Return(nil)
+	ctx := context.Background()
+
+	c.DB(ctx, "db")
+	db.C(ctx, "coll")
+	coll.Proc(ctx, "proc")
+	coll.Trigger(ctx, "trigger")
+	db.User(ctx, "user")
+	usr := &Usr{db: db, User: User{Resource: Resource{Id: "user", Self: "dbs/b5NCAA==/users/b5NCAOuS8wA=/"}}}
+	usr.Permission(ctx, "perm")
+	var doc Document
+	coll.ReadDocument(ctx, "foo", &doc)
+	coll.ReplaceDocument(ctx, "foo", doc, "")
+	coll.DeleteDocument(ctx, "foo", "")
+	coll.UpdateDocument(ctx, &Document{Resource: Resource{Id: "foo"}}, "")
+	client.AssertCalled(t, "Query", "dbs/db", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/colls/coll", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/colls/coll/sprocs/proc", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/colls/coll/triggers/trigger", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/users/user", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/users/user/permissions/perm", (*Query)(nil))
+	client.AssertCalled(t, "Query", "dbs/db/colls/coll/docs/foo", (*Query)(nil))
+	client.AssertNumberOfCalls(t, "Query", 7)
+	client.AssertNumberOfCalls(t, "Replace", 2)
+	client.AssertCalled(t, "Delete", "dbs/db/colls/coll/docs/foo")
+}
+
+func TestNameBasedEscaping(t *testing.T) {
+	assert := assert.New(t)
+	const id = "50%off now"
+	link := "dbs/db/colls/coll/docs/" + id
+	var paths []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		// signed with the unescaped link
+		req := ResourceRequest(link, r.Clone(r.Context()))
+		assert.Nil(MasterKey("YXJpZWwNCg==").Authorize(req))
+		assert.Equal(req.Header.Get(HEADER_AUTH), r.Header.Get(HEADER_AUTH))
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprintf(w, `{"id": %q}`, id)
+	}))
+	defer s.Close()
+	db := &DB{c: New(s.URL, Config{MasterKey: "YXJpZWwNCg=="}), Database: Database{Resource: Resource{Id: "db", Self: "dbs/b5NCAA==/"}}}
+	coll := &Col{db: db, Collection: Collection{Resource: Resource{Id: "coll", Self: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"}}}
+	ctx := context.Background()
+
+	var doc Document
+	assert.Nil(coll.ReadDocument(ctx, id, &doc))
+	assert.Equal(id, doc.Id)
+	_, err := coll.ReplaceDocument(ctx, id, doc, "")
+	assert.Nil(err)
+	_, err = coll.UpdateDocument(ctx, &doc, "")
+	assert.Nil(err)
+	assert.Nil(coll.DeleteDocument(ctx, id, ""))
+	_, err = Typed[Document](coll).Get(ctx, id)
+	assert.Nil(err)
+	escaped := "/dbs/db/colls/coll/docs/50%25off%20now"
+	assert.Equal([]string{"GET " + escaped, "PUT " + escaped, "PUT " + escaped, "DELETE " + escaped, "GET " + escaped}, paths)
+}
@@ -0,0 +1,94 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// hedgeKey marks the reads that may be hedged (i.e: point reads), legKey is
+// the index of the region a hedged read is sent to.
+type hedgeKey struct{}
+type legKey struct{}
+
+// Reports whether the request is sent to several regions, see
+// Config.HedgeThreshold
+func (c *Client) hedged(ctx context.Context, r *Request) bool {
+	ok, _ := ctx.Value(hedgeKey{}).(bool)
+	return ok && c.Config.HedgeThreshold > 0 && c.discovery() && isRead(r) && r.link != ""
+}
+
+// Send a read to the preferred region, and to the next one if it's not
+// answered within the threshold. The first success wins, and the other
+// request is cancelled.
+func (c *Client) hedge(ctx context.Context, r *Request, data interface{}) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		resp   *http.Response
+		header http.Header
+		info   ResponseInfo
+		body   json.RawMessage
+		err    error
+	}
+	results := make(chan result, 2)
+	send := func(leg int) {
+		var res result
+		// each leg captures its own response and its own ResponseInfo
+		lctx := context.WithValue(ctx, hedgeKey{}, false)
+		lctx = context.WithValue(lctx, legKey{}, leg)
+		lctx = withResponseHeader(lctx, &res.header)
+		lctx = context.WithValue(lctx, infoKey{}, &infoCapture{info: &res.info})
+		req := &Request{rId: r.rId, rType: r.rType, link: r.link, Request: r.Request.Clone(lctx)}
+		var out interface{}
+		if data != nil {
+			out = &res.body
+		}
+		res.resp, res.err = c.do(lctx, req, out)
+		results <- res
+	}
+	go send(0)
+	timer := time.NewTimer(c.Config.HedgeThreshold)
+	defer timer.Stop()
+	pending := 1
+	// the charge and the retries of all the completed legs
+	var (
+		charge  float64
+		retries int
+	)
+	for {
+		select {
+		case <-timer.C:
+			// there's no other region to send the read to
+			if c.endpoint(ctx, r) == c.endpoint(context.WithValue(ctx, legKey{}, 1), r) {
+				continue
+			}
+			pending++
+			go send(1)
+		case res := <-results:
+			pending--
+			charge += res.info.RequestCharge
+			retries += res.info.RetryCount
+			// wait for the other region
+			if res.err != nil && pending > 0 {
+				continue
+			}
+			// the response that is returned is captured, with the total
+			// charge of the legs
+			info := res.info
+			info.RequestCharge, info.RetryCount = charge, retries
+			mergeResponseInfo(ctx, &info)
+			if res.err != nil {
+				return res.resp, res.err
+			}
+			if h, ok := ctx.Value(respKey{}).(*http.Header); ok {
+				*h = res.header
+			}
+			if len(res.body) > 0 {
+				return res.resp, json.Unmarshal(res.body, data)
+			}
+			return res.resp, nil
+		}
+	}
+}
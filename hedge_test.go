@@ -0,0 +1,128 @@
+package documentdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgedRead(t *testing.T) {
+	assert := assert.New(t)
+	cancelled := make(chan struct{})
+	// the preferred region is slow
+	east := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer east.Close()
+	west := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CHARGE, "1")
+		fmt.Fprint(w, `{"id": "foo"}`)
+	}))
+	defer west.Close()
+	account := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"writableLocations": [{"name": "West US", "databaseAccountEndpoint": %q}],
+			"readableLocations": [
+				{"name": "West US", "databaseAccountEndpoint": %q},
+				{"name": "East US", "databaseAccountEndpoint": %q}
+			]
+		}`, west.URL, west.URL, east.URL)
+	}))
+	defer account.Close()
+	c := &DocumentDB{&Client{Url: account.URL, Config: Config{
+		MasterKey:        "YXJpZWwNCg==",
+		PreferredRegions: []string{"East US", "West US"},
+		HedgeThreshold:   20 * time.Millisecond,
+	}}}
+
+	var (
+		doc  Document
+		info ResponseInfo
+	)
+	start := time.Now()
+	err := c.ReadDocument(WithResponseInfo(context.Background(), &info), "dbs/db/colls/coll/docs/foo", &doc)
+	assert.Nil(err)
+	assert.Equal("foo", doc.Id)
+	assert.Equal(float64(1), info.RequestCharge)
+	assert.True(time.Since(start) < time.Second)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request should be cancelled")
+	}
+}
+
+func TestHedgedReadResponseInfo(t *testing.T) {
+	assert := assert.New(t)
+	// the preferred region fails while the other one is pending
+	east := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set(HEADER_CHARGE, "5")
+		w.Header().Set(HEADER_ACTIVITY_ID, "east")
+		http.Error(w, `{"code": "ServiceUnavailable", "message": "unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer east.Close()
+	west := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		w.Header().Set(HEADER_CHARGE, "1")
+		w.Header().Set(HEADER_ACTIVITY_ID, "west")
+		fmt.Fprint(w, `{"id": "foo"}`)
+	}))
+	defer west.Close()
+	account := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"writableLocations": [{"name": "West US", "databaseAccountEndpoint": %q}],
+			"readableLocations": [
+				{"name": "West US", "databaseAccountEndpoint": %q},
+				{"name": "East US", "databaseAccountEndpoint": %q}
+			]
+		}`, west.URL, west.URL, east.URL)
+	}))
+	defer account.Close()
+	c := &DocumentDB{&Client{Url: account.URL, Config: Config{
+		MasterKey:        "YXJpZWwNCg==",
+		PreferredRegions: []string{"East US", "West US"},
+		HedgeThreshold:   10 * time.Millisecond,
+	}}}
+
+	var (
+		doc  Document
+		info ResponseInfo
+	)
+	assert.Nil(c.ReadDocument(WithResponseInfo(context.Background(), &info), "dbs/db/colls/coll/docs/foo", &doc))
+	assert.Equal("foo", doc.Id)
+	assert.Equal(http.StatusOK, info.StatusCode)
+	assert.Equal("west", info.ActivityId)
+	// both regions were billed
+	assert.Equal(float64(6), info.RequestCharge)
+}
+
+func TestHedgedReadSingleRegion(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, `{"readableLocations": [{"name": "West US", "databaseAccountEndpoint": "http://%s"}]}`, r.Host)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, `{"id": "foo"}`)
+	}))
+	defer s.Close()
+	c := &DocumentDB{&Client{Url: s.URL, Config: Config{
+		MasterKey:        "YXJpZWwNCg==",
+		PreferredRegions: []string{"West US"},
+		HedgeThreshold:   time.Millisecond,
+	}}}
+	var doc Document
+	assert.Nil(c.ReadDocument(context.Background(), "dbs/db/colls/coll/docs/foo", &doc))
+	assert.Equal("foo", doc.Id)
+}
@@ -0,0 +1,134 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrIteratorClosed = errors.New("iterator closed")
+)
+
+// IteratorOptions controls the paging of a DocumentIterator.
+type IteratorOptions struct {
+	// PageSize is the max number of documents fetched per round trip.
+	// Zero lets the server decide.
+	PageSize int
+	// Limit caps the total number of documents the iterator returns.
+	// Zero means no limit.
+	Limit int
+}
+
+// fetchFunc read one page of documents into out and returns the continuation token
+type fetchFunc func(ctx context.Context, qu *Query, out interface{}) (string, error)
+
+// DocumentIterator walks over the results of a query (or a read feed) one
+// document at a time, following the continuation token until the results
+// are exhausted.
+//
+// Example:
+//
+//	it := coll.IterateDocuments(NewQuery("SELECT * FROM root r", nil), IteratorOptions{PageSize: 100})
+//	defer it.Close()
+//	for it.Next(ctx) {
+//		var doc MyDocument
+//		if err := it.Decode(&doc); err != nil {
+//			// ...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type DocumentIterator struct {
+	fetch   fetchFunc
+	query   Query
+	opts    IteratorOptions
+	token   string
+	page    []json.RawMessage
+	cur     json.RawMessage
+	count   int
+	fetched bool
+	closed  bool
+	err     error
+}
+
+func newDocumentIterator(fetch fetchFunc, qu *Query, opts IteratorOptions) *DocumentIterator {
+	it := &DocumentIterator{fetch: fetch, opts: opts}
+	if qu != nil {
+		it.query = *qu
+		it.token = qu.Token
+	}
+	return it
+}
+
+// Next advances the iterator to the next document, fetching the next page
+// when the current one is consumed. It returns false when the results are
+// exhausted, the limit is reached or an error occurred.
+func (it *DocumentIterator) Next(ctx context.Context) bool {
+	it.cur = nil
+	if it.closed || it.err != nil {
+		return false
+	}
+	if it.opts.Limit > 0 && it.count >= it.opts.Limit {
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.fetched && it.token == "" {
+			return false
+		}
+		if err := it.next(ctx); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	it.count++
+	return true
+}
+
+// fetch the next page
+func (it *DocumentIterator) next(ctx context.Context) error {
+	qu := it.query
+	qu.Token = it.token
+	qu.MaxItems = it.opts.PageSize
+	if n := it.opts.Limit - it.count; it.opts.Limit > 0 && (qu.MaxItems == 0 || n < qu.MaxItems) {
+		qu.MaxItems = n
+	}
+	var page []json.RawMessage
+	token, err := it.fetch(ctx, &qu, &page)
+	if err != nil {
+		return err
+	}
+	it.page, it.token, it.fetched = page, token, true
+	return nil
+}
+
+// Decode the current document into the given value
+func (it *DocumentIterator) Decode(v interface{}) error {
+	if it.closed {
+		return ErrIteratorClosed
+	}
+	if it.cur == nil {
+		return errors.New("no current document, call Next first")
+	}
+	return json.Unmarshal(it.cur, v)
+}
+
+// Token returns the continuation token of the next page. It can be used to
+// resume the iteration later on (i.e: Query.Token).
+func (it *DocumentIterator) Token() string {
+	return it.token
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (it *DocumentIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and releases the buffered page.
+func (it *DocumentIterator) Close() error {
+	it.closed = true
+	it.page, it.cur = nil, nil
+	return nil
+}
@@ -0,0 +1,76 @@
+package documentdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentIterator(t *testing.T) {
+	assert := assert.New(t)
+	var tokens, sizes []string
+	pages := []string{
+		`{"Documents": [{"id": "1"}, {"id": "2"}]}`,
+		`{"Documents": [{"id": "3"}, {"id": "4"}]}`,
+		`{"Documents": [{"id": "5"}]}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get(HEADER_CONTINUATION))
+		sizes = append(sizes, r.Header.Get(HEADER_MAX_ITEMS))
+		i := len(tokens) - 1
+		if i < len(pages)-1 {
+			w.Header().Set(HEADER_CONTINUATION, fmt.Sprintf("token%d", i+1))
+		}
+		fmt.Fprint(w, pages[i])
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	ctx := context.Background()
+
+	// Walk over all pages
+	it := c.IterateDocuments("coll/", nil, IteratorOptions{PageSize: 2})
+	var ids []string
+	for it.Next(ctx) {
+		var doc Document
+		assert.Nil(it.Decode(&doc))
+		ids = append(ids, doc.Id)
+	}
+	assert.Nil(it.Err())
+	assert.Equal([]string{"1", "2", "3", "4", "5"}, ids)
+	assert.Equal([]string{"", "token1", "token2"}, tokens)
+	assert.Equal([]string{"2", "2", "2"}, sizes)
+	assert.Nil(it.Close())
+
+	// Limit the total number of items
+	tokens, sizes = nil, nil
+	it = c.IterateDocuments("coll/", nil, IteratorOptions{PageSize: 2, Limit: 3})
+	ids = nil
+	for it.Next(ctx) {
+		var doc Document
+		assert.Nil(it.Decode(&doc))
+		ids = append(ids, doc.Id)
+	}
+	assert.Nil(it.Err())
+	assert.Equal([]string{"1", "2", "3"}, ids)
+	assert.Equal([]string{"2", "1"}, sizes)
+	assert.Equal("token2", it.Token())
+
+	// Closed iterator
+	it.Close()
+	assert.False(it.Next(ctx))
+	assert.Equal(ErrIteratorClosed, it.Decode(&Document{}))
+}
+
+func TestDocumentIteratorError(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(500)
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	it := c.IterateDocuments("coll/", NewQuery("SELECT * FROM root r", nil), IteratorOptions{})
+	assert.False(it.Next(context.Background()))
+	assert.Equal(it.Err().Error(), "500, DocumentDB error")
+}
@@ -0,0 +1,199 @@
+package documentdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RULimiter is a token bucket limiter of request units. The requests wait
+// until the bucket has tokens, and their actual request charge is taken from
+// the bucket once they complete. It's plugged to a client as a middleware.
+//
+// Example:
+//
+//	limiter := NewRULimiter(400, 1000)
+//	client := New(url, Config{
+//		MasterKey:   key,
+//		Middlewares: []Middleware{limiter.Middleware},
+//	})
+type RULimiter struct {
+	mu     sync.Mutex
+	rate   float64 // RU per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRULimiter returns a limiter of rate RU/s, that allows bursts of up to
+// burst RUs. If burst is zero, it's equal to the rate. It panics if the rate
+// is not positive.
+func NewRULimiter(rate, burst float64) *RULimiter {
+	if rate <= 0 {
+		panic("documentdb: non-positive rate for NewRULimiter")
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &RULimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Refill the bucket, must be called with the lock held
+func (l *RULimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// Wait until the bucket has tokens, or the context is done
+func (l *RULimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.tokens > 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		d := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Charge takes the given request units from the bucket
+func (l *RULimiter) Charge(ru float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= ru
+}
+
+// Middleware limits the requests of a client
+func (l *RULimiter) Middleware(next Handler) Handler {
+	return func(ctx context.Context, r *Request) (*http.Response, error) {
+		if err := l.Wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := next(ctx, r)
+		if err == nil {
+			if ru, err := strconv.ParseFloat(resp.Header.Get(HEADER_CHARGE), 64); err == nil {
+				l.Charge(ru)
+			}
+		}
+		return resp, err
+	}
+}
+
+// ErrCircuitOpen is returned for the requests of a collection that its
+// circuit is open. It may be wrapped (e.g: by a RetryError, when the circuit
+// was opened while the request was retried), use errors.Is to check it.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitBreaker fails fast the requests of a collection that was throttled
+// (429), unavailable (503) or unreachable for Threshold consecutive attempts.
+// Requests that are not made on a collection (e.g: databases) are not
+// affected. The collections are keyed like their sessions (i.e: by self link
+// for the requests made with Col). The circuit
+// stays open for Cooldown, then a single trial request is let through: if it
+// succeeds the circuit is closed, otherwise it's opened again. It's plugged to
+// a client as a middleware.
+//
+// Example:
+//
+//	breaker := NewCircuitBreaker(10, 5*time.Second)
+//	client := New(url, Config{
+//		MasterKey:   key,
+//		Middlewares: []Middleware{breaker.Middleware},
+//	})
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	mu        sync.Mutex
+	circuits  map[string]*circuit // collection link => circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	trial     bool // a trial request is in flight
+}
+
+// NewCircuitBreaker returns a circuit breaker with the given threshold and
+// cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Reports whether a request of the collection is allowed
+func (b *CircuitBreaker) allow(coll string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[coll]
+	if !ok || c.openUntil.IsZero() {
+		return true
+	}
+	if c.trial || time.Now().Before(c.openUntil) {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// Record the status of a collection response, or its transport error (status
+// 0). Canceled requests (status -1) only let another trial request through.
+func (b *CircuitBreaker) record(coll string, status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[coll]
+	if !ok {
+		c = &circuit{}
+		b.circuits[coll] = c
+	}
+	if status == -1 {
+		c.trial = false
+		return
+	}
+	if status != 0 && status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		*c = circuit{}
+		return
+	}
+	c.failures++
+	if c.trial || c.failures >= b.Threshold {
+		c.openUntil = time.Now().Add(b.Cooldown)
+		c.trial = false
+	}
+}
+
+// Middleware fails fast the requests of the open circuits
+func (b *CircuitBreaker) Middleware(next Handler) Handler {
+	return func(ctx context.Context, r *Request) (*http.Response, error) {
+		coll := collectionKey(ctx, r)
+		if coll == "" {
+			return next(ctx, r)
+		}
+		if !b.allow(coll) {
+			return nil, ErrCircuitOpen
+		}
+		resp, err := next(ctx, r)
+		status := 0
+		switch {
+		case err == nil:
+			status = resp.StatusCode
+		case ctx.Err() != nil:
+			status = -1
+		}
+		b.record(coll, status)
+		return resp, err
+	}
+}
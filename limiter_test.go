@@ -0,0 +1,149 @@
+package documentdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRULimiter(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CHARGE, "20")
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+	limiter := NewRULimiter(1000, 10)
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{limiter.Middleware}}}
+	ctx := context.Background()
+
+	var doc Document
+	start := time.Now()
+	_, err := client.Query(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", nil, &doc, nil)
+	assert.Nil(err)
+	// the bucket is in debt of 10 RUs, the next request waits for ~10ms
+	_, err = client.Query(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", nil, &doc, nil)
+	assert.Nil(err)
+	assert.True(time.Since(start) >= 10*time.Millisecond)
+
+	// canceled while waiting
+	limiter.Charge(1000)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Query(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", nil, &doc, nil)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	assert.Panics(func() { NewRULimiter(0, 10) })
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	status := http.StatusTooManyRequests
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+	breaker := NewCircuitBreaker(2, 20*time.Millisecond)
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{breaker.Middleware}}}
+	ctx := context.Background()
+	read := func(link string) error {
+		var doc Document
+		_, err := client.Query(ctx, link, nil, &doc, nil)
+		return err
+	}
+	coll, other := "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", "dbs/b5NCAA==/colls/b5NCAKzqWAA=/docs/"
+
+	assert.NotNil(read(coll))
+	assert.NotNil(read(coll))
+	assert.Equal(2, calls)
+	// the circuit is open
+	assert.Equal(ErrCircuitOpen, read(coll))
+	assert.Equal(2, calls)
+	assert.NotNil(read(other))
+	assert.Equal(3, calls, "should not affect other collections")
+
+	// trial request fails, the circuit is opened again
+	time.Sleep(30 * time.Millisecond)
+	assert.NotNil(read(coll))
+	assert.Equal(4, calls)
+	assert.Equal(ErrCircuitOpen, read(coll))
+
+	// trial request succeeds, the circuit is closed
+	time.Sleep(30 * time.Millisecond)
+	status = http.StatusOK
+	assert.Nil(read(coll))
+	assert.Nil(read(coll))
+	assert.Equal(6, calls)
+}
+
+func TestCircuitBreakerScope(t *testing.T) {
+	assert := assert.New(t)
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	breaker := NewCircuitBreaker(1, time.Minute)
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{breaker.Middleware}}}
+	ctx := context.Background()
+
+	// requests without a collection have no circuit
+	assert.NotNil(client.Create(ctx, "dbs", nil, nil, nil))
+	assert.NotNil(client.Create(ctx, "dbs", nil, nil, nil))
+	assert.Equal(2, calls)
+	assert.Empty(breaker.circuits)
+
+	// unreachable collections open the circuit
+	s.Close()
+	coll := "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/"
+	err := client.Create(ctx, coll, nil, nil, nil)
+	assert.NotNil(err)
+	assert.NotEqual(ErrCircuitOpen, err)
+	assert.Equal(ErrCircuitOpen, client.Create(ctx, coll, nil, nil, nil))
+}
+
+func TestCircuitBreakerCol(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	breaker := NewCircuitBreaker(1, time.Minute)
+	db := &DB{c: New(s.URL, Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{breaker.Middleware}}), Database: Database{Resource: Resource{Id: "db", Self: "dbs/b5NCAA==/"}}}
+	coll := &Col{db: db, Collection: Collection{Resource: Resource{Id: "coll", Self: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"}}}
+	ctx := context.Background()
+
+	// the name based and the self links of the collection share a circuit
+	var doc Document
+	assert.NotEqual(ErrCircuitOpen, coll.ReadDocument(ctx, "foo", &doc))
+	var docs []Document
+	_, err := coll.QueryDocuments(ctx, nil, &docs)
+	assert.Equal(ErrCircuitOpen, err)
+	assert.Len(breaker.circuits, 1)
+}
+
+func TestCircuitBreakerRetried(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_RETRY_AFTER, "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	breaker := NewCircuitBreaker(1, time.Minute)
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", MaxRetries: 1, Middlewares: []Middleware{breaker.Middleware}}}
+
+	// the circuit is opened by the first attempt, and the retry fails fast
+	err := client.Create(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", nil, nil, nil)
+	var re *RetryError
+	assert.True(errors.As(err, &re))
+	assert.True(errors.Is(err, ErrCircuitOpen))
+}
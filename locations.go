@@ -0,0 +1,280 @@
+package documentdb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// default interval of the account locations refresh
+	defaultEndpointRefresh = 5 * time.Minute
+	// time an unreachable endpoint is skipped
+	unavailableFor = 5 * time.Minute
+	// timeout of a locations refresh
+	refreshTimeout = 10 * time.Second
+	// max number of failovers of a request
+	maxFailovers = 3
+	// x-ms-substatus of writes sent to a region that is not writable
+	subStatusWriteForbidden = 3
+)
+
+// Endpoints of the account regions. The locations are discovered by reading
+// the account, and refreshed periodically.
+type locations struct {
+	mu          sync.Mutex
+	write       []*url.URL // write endpoints, by preference
+	read        []*url.URL // read endpoints, by preference
+	refreshed   time.Time
+	refreshing  bool
+	unavailable map[string]time.Time // endpoint host => marked at
+	// serializes the refreshes
+	refreshMu sync.Mutex
+}
+
+// Update the endpoints from the account locations, ordered by the preferred
+// regions. Reads fall back to the other regions of the account.
+func (l *locations) update(acc *DatabaseAccount, preferred []string) {
+	write := endpointsOf(acc.WritableLocations, nil)
+	if acc.EnableMultipleWriteLocations {
+		write = uniqueEndpoints(endpointsOf(acc.WritableLocations, preferred), write)
+	}
+	read := uniqueEndpoints(endpointsOf(acc.ReadableLocations, preferred), endpointsOf(acc.ReadableLocations, nil), write)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.write, l.read = write, read
+}
+
+// Return the endpoints of the given locations. If preferred is given, only
+// the preferred regions are returned, in order of preference.
+func endpointsOf(locs []Location, preferred []string) []*url.URL {
+	var names []string
+	if preferred == nil {
+		for _, loc := range locs {
+			names = append(names, loc.Name)
+		}
+	} else {
+		names = preferred
+	}
+	var urls []*url.URL
+	for _, name := range names {
+		for _, loc := range locs {
+			if !strings.EqualFold(loc.Name, name) {
+				continue
+			}
+			if u, err := url.Parse(loc.Endpoint); err == nil && u.Host != "" {
+				urls = append(urls, u)
+			}
+			break
+		}
+	}
+	return urls
+}
+
+// Concatenate the given endpoints, without duplicates
+func uniqueEndpoints(lists ...[]*url.URL) []*url.URL {
+	var urls []*url.URL
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, u := range list {
+			if !seen[u.Host] {
+				seen[u.Host] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// Return the endpoint of the request, skipping the unreachable ones and the
+// given number of reachable ones (i.e: the regions of a hedged read). It
+// returns nil if the locations are unknown.
+func (l *locations) endpoint(r *Request, skip int) *url.URL {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	urls := l.write
+	if isRead(r) {
+		urls = l.read
+	}
+	var last *url.URL
+	for _, u := range urls {
+		if t, ok := l.unavailable[u.Host]; !ok || time.Since(t) > unavailableFor {
+			if last = u; skip == 0 {
+				return u
+			}
+			skip--
+		}
+	}
+	if last != nil {
+		return last
+	}
+	// all the endpoints are unreachable, try the preferred one
+	if len(urls) > 0 {
+		return urls[0]
+	}
+	return nil
+}
+
+// Return all the known endpoints
+func (l *locations) endpoints() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var eps []string
+	for _, u := range uniqueEndpoints(l.write, l.read) {
+		eps = append(eps, u.Scheme+"://"+u.Host)
+	}
+	return eps
+}
+
+// Mark an endpoint as unreachable
+func (l *locations) markUnavailable(u *url.URL) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unavailable == nil {
+		l.unavailable = make(map[string]time.Time)
+	}
+	l.unavailable[u.Host] = time.Now()
+}
+
+// Reports whether the request only reads (i.e: reads and queries)
+func isRead(r *Request) bool {
+	switch r.Method {
+	case "GET", "HEAD":
+		return true
+	case "POST":
+		return r.Header.Get(HEADER_IS_QUERY) != "" || r.Header.Get(HEADER_QUERY_PLAN) != ""
+	}
+	return false
+}
+
+// Reports whether the endpoint discovery is enabled
+func (c *Client) discovery() bool {
+	return len(c.Config.PreferredRegions) > 0
+}
+
+// Return the regional endpoint of the request, or nil to send it to the
+// account endpoint. The locations are read on the first request, and
+// refreshed in the background afterward.
+func (c *Client) endpoint(ctx context.Context, r *Request) *url.URL {
+	// the account is read from the given endpoint
+	if !c.discovery() || r.link == "" {
+		return nil
+	}
+	interval := c.Config.EndpointRefresh
+	if interval == 0 {
+		interval = defaultEndpointRefresh
+	}
+	l := &c.locations
+	l.mu.Lock()
+	switch last := l.refreshed; {
+	case last.IsZero():
+		l.mu.Unlock()
+		c.refreshLocations(ctx, last)
+	case time.Since(last) > interval && !l.refreshing:
+		l.refreshing = true
+		l.mu.Unlock()
+		go c.refreshLocations(context.Background(), last)
+	default:
+		l.mu.Unlock()
+	}
+	leg, _ := ctx.Value(legKey{}).(int)
+	return l.endpoint(r, leg)
+}
+
+// Return the time of the last refresh of the locations
+func (l *locations) lastRefresh() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.refreshed
+}
+
+// Read the account locations from the account endpoint, or from one of the
+// known regions if it's unreachable. The locations are not read again if they
+// were refreshed after last (i.e: by a concurrent caller). The refresh stops
+// when the given context is done.
+func (c *Client) refreshLocations(ctx context.Context, last time.Time) (err error) {
+	l := &c.locations
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+	if l.lastRefresh().After(last) {
+		return nil
+	}
+	defer func() {
+		l.mu.Lock()
+		// the caller gave up, the next request reads the locations again
+		if err == nil || ctx.Err() == nil {
+			l.refreshed = time.Now()
+		}
+		l.refreshing = false
+		l.mu.Unlock()
+	}()
+	// only the cancellation of the caller is used, its values (e.g: the
+	// response capture) belong to the request
+	rctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+	defer context.AfterFunc(ctx, cancel)()
+	for _, ep := range append([]string{c.Url}, l.endpoints()...) {
+		var acc DatabaseAccount
+		if err = c.readAccount(rctx, ep, &acc); err == nil {
+			l.update(&acc, c.Config.PreferredRegions)
+			return nil
+		}
+	}
+	return err
+}
+
+// Read the account from the given endpoint
+func (c *Client) readAccount(ctx context.Context, endpoint string, acc *DatabaseAccount) error {
+	req, err := http.NewRequest("GET", path(strings.TrimRight(endpoint, "/"), ""), nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, ResourceRequest("", req), acc)
+	return err
+}
+
+// Decide if a failed attempt is sent again to another region. Unreachable
+// endpoints are skipped for a while, and writes rejected by a region that is
+// no longer writable refresh the locations.
+func (c *Client) failover(ctx context.Context, r *Request, ep *url.URL, resp *http.Response, err error) bool {
+	l := &c.locations
+	if err != nil {
+		if !unreachable(err) {
+			return false
+		}
+		l.markUnavailable(ep)
+		l.mu.Lock()
+		if !l.refreshing {
+			l.refreshing = true
+			go c.refreshLocations(context.Background(), l.refreshed)
+		}
+		l.mu.Unlock()
+		return idempotent(r)
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		if sub, _ := strconv.Atoi(resp.Header.Get(HEADER_SUBSTATUS)); sub == subStatusWriteForbidden {
+			return c.refreshLocations(ctx, l.lastRefresh()) == nil
+		}
+	}
+	return false
+}
+
+// Reports whether the error is a failure to connect to the endpoint, unlike
+// timeouts or cancellations that may happen after the request was received.
+func unreachable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,223 @@
+package documentdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Region server that records the requested links
+type regionServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	links []string
+	// status of the next responses
+	status []int
+}
+
+func newRegionServer(body func() string) *regionServer {
+	s := &regionServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.links = append(s.links, r.Method+" "+r.URL.Path)
+		status := http.StatusOK
+		if len(s.status) > 0 {
+			status, s.status = s.status[0], s.status[1:]
+		}
+		s.mu.Unlock()
+		if status == http.StatusForbidden {
+			w.Header().Set(HEADER_SUBSTATUS, "3")
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body())
+	}))
+	return s
+}
+
+func (s *regionServer) Links() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.links...)
+}
+
+func TestLocations(t *testing.T) {
+	assert := assert.New(t)
+	doc := func() string { return `{"id": "foo"}` }
+	west, east := newRegionServer(doc), newRegionServer(doc)
+	defer west.Close()
+	var (
+		mu    sync.Mutex
+		write = "West US"
+	)
+	account := newRegionServer(func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		w := west.URL
+		if write == "East US" {
+			w = east.URL
+		}
+		return fmt.Sprintf(`{
+			"id": "account",
+			"writableLocations": [{"name": %q, "databaseAccountEndpoint": "%s/"}],
+			"readableLocations": [
+				{"name": "West US", "databaseAccountEndpoint": "%s/"},
+				{"name": "East US", "databaseAccountEndpoint": "%s/"}
+			]
+		}`, write, w, west.URL, east.URL)
+	})
+	defer account.Close()
+	client := &Client{Url: account.URL, Config: Config{MasterKey: "YXJpZWwNCg==", PreferredRegions: []string{"east us"}}}
+	ctx := context.Background()
+
+	// reads are sent to the preferred region, writes to the write region
+	var doc1 Document
+	_, err := client.Query(ctx, "dbs/db/colls/coll/docs/foo", nil, &doc1, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"GET /"}, account.Links())
+	assert.Equal([]string{"GET /dbs/db/colls/coll/docs/foo"}, east.Links())
+	assert.Nil(client.Create(ctx, "dbs/db/colls/coll/docs", doc1, &doc1, nil))
+	assert.Equal([]string{"POST /dbs/db/colls/coll/docs"}, west.Links())
+
+	// writes rejected by a region that is no longer writable refresh the
+	// locations
+	mu.Lock()
+	write = "East US"
+	mu.Unlock()
+	west.mu.Lock()
+	west.status = []int{http.StatusForbidden}
+	west.mu.Unlock()
+	assert.Nil(client.Create(ctx, "dbs/db/colls/coll/docs", doc1, &doc1, nil))
+	assert.Len(west.Links(), 2)
+	assert.Equal("POST /dbs/db/colls/coll/docs", east.Links()[1])
+	assert.Len(account.Links(), 2)
+
+	// unreachable regions are skipped
+	east.Close()
+	_, err = client.Query(ctx, "dbs/db/colls/coll/docs/foo", nil, &doc1, nil)
+	assert.Nil(err)
+	_, err = client.Query(ctx, "dbs/db/colls/coll/docs/foo", nil, &doc1, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"GET /dbs/db/colls/coll/docs/foo", "GET /dbs/db/colls/coll/docs/foo"}, west.Links()[2:])
+}
+
+func TestLocationsFailoverIsNotRetry(t *testing.T) {
+	assert := assert.New(t)
+	doc := func() string { return `{"code": "NotFound", "message": "Resource Not Found"}` }
+	west, east := newRegionServer(doc), newRegionServer(doc)
+	defer west.Close()
+	account := newRegionServer(func() string {
+		return fmt.Sprintf(`{
+			"writableLocations": [{"name": "West US", "databaseAccountEndpoint": "%s/"}],
+			"readableLocations": [
+				{"name": "West US", "databaseAccountEndpoint": "%s/"},
+				{"name": "East US", "databaseAccountEndpoint": "%s/"}
+			]
+		}`, west.URL, west.URL, east.URL)
+	})
+	defer account.Close()
+	var counts countsPolicy
+	client := &Client{Url: account.URL, Config: Config{MasterKey: "YXJpZWwNCg==", PreferredRegions: []string{"East US"}, RetryPolicy: &counts}}
+	east.Close()
+	west.status = []int{http.StatusNotFound, http.StatusNotFound}
+
+	var (
+		d    Document
+		info ResponseInfo
+	)
+	_, err := client.Query(WithResponseInfo(context.Background(), &info), "dbs/db/colls/coll/docs/foo", nil, &d, nil)
+	assert.True(errors.Is(err, ErrNotFound))
+	// the failover from the unreachable region is not a retry
+	assert.Equal(countsPolicy{1, 2}, counts)
+	var re *RetryError
+	assert.True(errors.As(err, &re))
+	assert.Equal(2, re.Attempts)
+	assert.Equal(1, info.RetryCount)
+	assert.Equal([]string{"GET /dbs/db/colls/coll/docs/foo", "GET /dbs/db/colls/coll/docs/foo"}, west.Links())
+}
+
+func TestLocationsRefresh(t *testing.T) {
+	assert := assert.New(t)
+	region := newRegionServer(func() string { return `{"id": "foo"}` })
+	defer region.Close()
+	account := newRegionServer(func() string {
+		return fmt.Sprintf(`{
+			"writableLocations": [{"name": "West US", "databaseAccountEndpoint": "%s/"}],
+			"readableLocations": [{"name": "West US", "databaseAccountEndpoint": "%s/"}]
+		}`, region.URL, region.URL)
+	})
+	defer account.Close()
+	client := &Client{Url: account.URL, Config: Config{MasterKey: "YXJpZWwNCg==", PreferredRegions: []string{"West US"}}}
+
+	// concurrent first requests read the account once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var d Document
+			_, err := client.Query(context.Background(), "dbs/db/colls/coll/docs/foo", nil, &d, nil)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal([]string{"GET /"}, account.Links())
+	assert.Len(region.Links(), 10)
+}
+
+func TestLocationsRefreshContext(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	account := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		http.Error(w, `{"code": "ServiceUnavailable", "message": "unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer account.Close()
+	defer close(release)
+	client := &Client{Url: account.URL, Config: Config{MasterKey: "YXJpZWwNCg==", PreferredRegions: []string{"West US"}}}
+
+	// the discovery stops with the context of the request
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var d Document
+	_, err := client.Query(ctx, "dbs/db/colls/coll/docs/foo", nil, &d, nil)
+	assert.NotNil(err)
+	assert.True(time.Since(start) < refreshTimeout)
+	assert.True(client.locations.lastRefresh().IsZero(), "the next request reads the account again")
+}
+
+func TestUnreachable(t *testing.T) {
+	assert := assert.New(t)
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	assert.True(unreachable(&url.Error{Op: "Get", URL: "https://west", Err: dial}))
+	assert.True(unreachable(&url.Error{Op: "Get", URL: "https://west", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}))
+	assert.False(unreachable(&url.Error{Op: "Get", URL: "https://west", Err: context.DeadlineExceeded}))
+	assert.False(unreachable(&url.Error{Op: "Get", URL: "https://west", Err: &net.OpError{Op: "dial", Net: "tcp", Err: context.Canceled}}))
+	assert.False(unreachable(&url.Error{Op: "Get", URL: "https://west", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}}))
+	assert.False(unreachable(errors.New("invalid character")))
+}
+
+func TestLocationsDisabled(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "foo"}`)
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg=="}}
+	var doc Document
+	_, err := client.Query(context.Background(), "dbs/db/colls/coll/docs/foo", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Equal("foo", doc.Id)
+}
@@ -1,5 +1,7 @@
 package documentdb
 
+import "encoding/json"
+
 // Resource
 type Resource struct {
 	Id   string `json:"id,omitempty"`
@@ -9,6 +11,23 @@ type Resource struct {
 	Ts   int    `json:"_ts,omitempty"`
 }
 
+type ConsistencyLevel string
+
+const (
+	Strong           = ConsistencyLevel("Strong")
+	BoundedStaleness = ConsistencyLevel("BoundedStaleness")
+	Session          = ConsistencyLevel("Session")
+	ConsistentPrefix = ConsistencyLevel("ConsistentPrefix")
+	Eventual         = ConsistencyLevel("Eventual")
+)
+
+type IndexingDirective string
+
+const (
+	IndexingInclude = IndexingDirective("Include")
+	IndexingExclude = IndexingDirective("Exclude")
+)
+
 type IndexingMode string
 
 const (
@@ -59,6 +78,26 @@ type ExcludedPath struct {
 	Path string `json:"path"`
 }
 
+type PartitionKind string
+
+const (
+	HashPartition = PartitionKind("Hash")
+)
+
+// Partition key definition of a partitioned collection
+type PartitionKeyDefinition struct {
+	Paths []string      `json:"paths"`
+	Kind  PartitionKind `json:"kind,omitempty"`
+}
+
+// Partition key range of a partitioned collection
+type PartitionKeyRange struct {
+	Resource
+	MinInclusive string   `json:"minInclusive"`
+	MaxExclusive string   `json:"maxExclusive"`
+	Parents      []string `json:"parents,omitempty"`
+}
+
 // Database
 type Database struct {
 	Resource
@@ -69,12 +108,13 @@ type Database struct {
 // Collection
 type Collection struct {
 	Resource
-	IndexingPolicy *IndexingPolicy `json:"indexingPolicy,omitempty"`
-	Docs           string          `json:"_docs,omitempty"`
-	Udf            string          `json:"_udfs,omitempty"`
-	Sporcs         string          `json:"_sporcs,omitempty"`
-	Triggers       string          `json:"_triggers,omitempty"`
-	Conflicts      string          `json:"_conflicts,omitempty"`
+	IndexingPolicy *IndexingPolicy         `json:"indexingPolicy,omitempty"`
+	PartitionKey   *PartitionKeyDefinition `json:"partitionKey,omitempty"`
+	Docs           string                  `json:"_docs,omitempty"`
+	Udf            string                  `json:"_udfs,omitempty"`
+	Sporcs         string                  `json:"_sporcs,omitempty"`
+	Triggers       string                  `json:"_triggers,omitempty"`
+	Conflicts      string                  `json:"_conflicts,omitempty"`
 }
 
 // Document
@@ -83,14 +123,130 @@ type Document struct {
 	Attachments string `json:"attachments,omitempty"`
 }
 
+// User
+type User struct {
+	Resource
+	Permissions string `json:"_permissions,omitempty"`
+}
+
+type PermissionMode string
+
+const (
+	PermissionAll  = PermissionMode("All")
+	PermissionRead = PermissionMode("Read")
+)
+
+// Permission
+type Permission struct {
+	Resource
+	PermissionMode       PermissionMode `json:"permissionMode"`
+	ResourceLink         string         `json:"resource"`
+	ResourcePartitionKey []interface{}  `json:"resourcePartitionKey,omitempty"`
+	Token                string         `json:"_token,omitempty"`
+}
+
+// Offer is the provisioned throughput of a collection (or a database)
+type Offer struct {
+	Resource
+	OfferVersion    string       `json:"offerVersion,omitempty"`
+	OfferType       string       `json:"offerType,omitempty"`
+	Content         OfferContent `json:"content"`
+	ResourceLink    string       `json:"resource,omitempty"`
+	OfferResourceId string       `json:"offerResourceId,omitempty"`
+}
+
+type OfferContent struct {
+	OfferThroughput int `json:"offerThroughput"`
+}
+
 // Stored Procedure
 type Sproc struct {
 	Resource
 	Body string `json:"body,omitempty"`
 }
 
+type TriggerType string
+
+const (
+	TriggerPre  = TriggerType("Pre")
+	TriggerPost = TriggerType("Post")
+)
+
+type TriggerOperation string
+
+const (
+	TriggerAll     = TriggerOperation("All")
+	TriggerCreate  = TriggerOperation("Create")
+	TriggerReplace = TriggerOperation("Replace")
+	TriggerDelete  = TriggerOperation("Delete")
+)
+
+// Attachment, Media is the link of the raw content. It's either a link to
+// an external storage, or a link to a media managed by the service.
+type Attachment struct {
+	Resource
+	ContentType string `json:"contentType,omitempty"`
+	Media       string `json:"media,omitempty"`
+}
+
+// Trigger
+type Trigger struct {
+	Resource
+	Body      string           `json:"body,omitempty"`
+	Type      TriggerType      `json:"triggerType,omitempty"`
+	Operation TriggerOperation `json:"triggerOperation,omitempty"`
+}
+
 // User Defined Function
 type UDF struct {
 	Resource
 	Body string `json:"body,omitempty"`
 }
+
+// DatabaseAccount is the root resource of an account
+type DatabaseAccount struct {
+	Resource
+	Dbs                          string                   `json:"_dbs,omitempty"`
+	Media                        string                   `json:"media,omitempty"`
+	Addresses                    string                   `json:"addresses,omitempty"`
+	WritableLocations            []Location               `json:"writableLocations,omitempty"`
+	ReadableLocations            []Location               `json:"readableLocations,omitempty"`
+	EnableMultipleWriteLocations bool                     `json:"enableMultipleWriteLocations,omitempty"`
+	ConsistencyPolicy            *ConsistencyPolicy       `json:"userConsistencyPolicy,omitempty"`
+	QueryEngineConfiguration     QueryEngineConfiguration `json:"queryEngineConfiguration,omitempty"`
+	// MaxMediaStorageUsageInMB and CurrentMediaStorageUsageInMB are the
+	// attachments media storage quota and usage, read from the response
+	// headers.
+	MaxMediaStorageUsageInMB     int64 `json:"-"`
+	CurrentMediaStorageUsageInMB int64 `json:"-"`
+}
+
+// Default consistency of an account
+type ConsistencyPolicy struct {
+	DefaultConsistencyLevel ConsistencyLevel `json:"defaultConsistencyLevel"`
+	// bounded staleness settings
+	MaxStalenessPrefix   int `json:"maxStalenessPrefix,omitempty"`
+	MaxIntervalInSeconds int `json:"maxIntervalInSeconds,omitempty"`
+}
+
+// QueryEngineConfiguration is the limits and the features of the query engine
+// of an account (e.g: "maxSqlQueryInputLength").
+type QueryEngineConfiguration map[string]interface{}
+
+// The configuration is sent as a JSON encoded string
+func (q *QueryEngineConfiguration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s == "" {
+			return nil
+		}
+		b = []byte(s)
+	}
+	return json.Unmarshal(b, (*map[string]interface{})(q))
+}
+
+// Location is a region of an account
+type Location struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"databaseAccountEndpoint"`
+}
@@ -0,0 +1,134 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// Throughput returns the provisioned throughput of the collection in RU/s
+func (c *Col) Throughput(ctx context.Context, opts ...*RequestOptions) (int, error) {
+	return c.db.c.ReadThroughput(c.ctx(ctx), c.Self, opts...)
+}
+
+// SetThroughput change the provisioned throughput of the collection
+func (c *Col) SetThroughput(ctx context.Context, ru int, opts ...*RequestOptions) error {
+	_, err := c.db.c.ReplaceThroughput(c.ctx(ctx), c.Self, ru, opts...)
+	return err
+}
+
+// Read offer by self link
+func (c *DocumentDB) ReadOffer(ctx context.Context, link string, opts ...*RequestOptions) (offer *Offer, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &offer, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read all offers
+func (c *DocumentDB) ReadOffers(ctx context.Context, opts ...*RequestOptions) (offers []Offer, err error) {
+	return c.QueryOffers(ctx, nil, opts...)
+}
+
+// Read all offers that satisfy a query
+func (c *DocumentDB) QueryOffers(ctx context.Context, query *Query, opts ...*RequestOptions) (offers []Offer, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Offers []Offer `json:"Offers,omitempty"`
+		Count  int     `json:"_count,omitempty"`
+	}
+	_, err = c.client.Query(ctx, "offers", query, &data, headers)
+	if offers = data.Offers; err != nil {
+		offers = nil
+	}
+	return
+}
+
+// Read the offer of a resource by its self link (e.g: collection self link)
+func (c *DocumentDB) ResourceOffer(ctx context.Context, link string, opts ...*RequestOptions) (*Offer, error) {
+	offers, err := c.QueryOffers(ctx, resourceOfferQuery(link), opts...)
+	if err != nil {
+		return nil, err
+	} else if len(offers) == 0 {
+		return nil, ErrNotFound
+	}
+	return &offers[0], nil
+}
+
+func resourceOfferQuery(link string) *Query {
+	return NewQuery(
+		"SELECT * FROM ROOT r WHERE r.resource = @link",
+		map[string]interface{}{"@link": link},
+	)
+}
+
+// Read the provisioned throughput of a resource by its self link
+func (c *DocumentDB) ReadThroughput(ctx context.Context, link string, opts ...*RequestOptions) (int, error) {
+	offer, err := c.ResourceOffer(ctx, link, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return offer.Content.OfferThroughput, nil
+}
+
+// Replace the provisioned throughput of a resource by its self link
+func (c *DocumentDB) ReplaceThroughput(ctx context.Context, link string, ru int, opts ...*RequestOptions) (*Offer, error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	// the offer is replaced as read, so the properties that Offer doesn't
+	// model (e.g: autoscale settings) are kept
+	var data struct {
+		Offers []map[string]json.RawMessage `json:"Offers,omitempty"`
+	}
+	if _, err := c.client.Query(ctx, "offers", resourceOfferQuery(link), &data, headers); err != nil {
+		return nil, err
+	} else if len(data.Offers) == 0 {
+		return nil, ErrNotFound
+	}
+	offer := data.Offers[0]
+	var (
+		self    string
+		content map[string]json.RawMessage
+	)
+	if err := json.Unmarshal(offer["_self"], &self); err != nil {
+		return nil, err
+	}
+	if b, ok := offer["content"]; ok {
+		if err := json.Unmarshal(b, &content); err != nil {
+			return nil, err
+		}
+	}
+	if content == nil {
+		content = make(map[string]json.RawMessage)
+	}
+	content["offerThroughput"] = json.RawMessage(strconv.Itoa(ru))
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	offer["content"] = b
+	return c.ReplaceOffer(ctx, self, offer, opts...)
+}
+
+// Replace offer
+func (c *DocumentDB) ReplaceOffer(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (offer *Offer, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &offer, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOffers(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "offers", (*Query)(nil)).Return("", nil)
+	client.On("Query", "offer_link", (*Query)(nil)).Return("", nil)
+	ctx := context.Background()
+	c.ReadOffers(ctx)
+	c.ReadOffer(ctx, "offer_link")
+	client.AssertCalled(t, "Query", "offers", (*Query)(nil))
+	client.AssertCalled(t, "Query", "offer_link", (*Query)(nil))
+}
+
+func TestReplaceOffer(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Replace", "offer_link", "{}").Return(nil)
+	c.ReplaceOffer(context.Background(), "offer_link", "{}")
+	client.AssertCalled(t, "Replace", "offer_link", "{}")
+}
+
+func TestColThroughput(t *testing.T) {
+	assert := assert.New(t)
+	offer := Offer{
+		OfferVersion: "V2",
+		Content:      OfferContent{OfferThroughput: 400},
+		ResourceLink: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/",
+	}
+	offer.Self = "offers/abc/"
+	var queries []Query
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/offers":
+			var q Query
+			json.NewDecoder(r.Body).Decode(&q)
+			queries = append(queries, q)
+			json.NewEncoder(w).Encode(map[string]interface{}{"Offers": []Offer{offer}})
+		case r.Method == "PUT" && r.URL.Path == "/offers/abc/":
+			b, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(b, &offer)
+			w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	coll := &Col{db: &DB{c: c}, Collection: Collection{Resource: Resource{Self: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"}}}
+	ctx := context.Background()
+
+	ru, err := coll.Throughput(ctx)
+	assert.Nil(err)
+	assert.Equal(400, ru)
+	assert.Equal([]QueryParam{{Name: "@link", Value: coll.Self}}, queries[0].Params)
+
+	assert.Nil(coll.SetThroughput(ctx, 1000))
+	assert.Equal(1000, offer.Content.OfferThroughput)
+	assert.Equal("V2", offer.OfferVersion)
+
+	ru, err = coll.Throughput(ctx)
+	assert.Nil(err)
+	assert.Equal(1000, ru)
+}
+
+func TestReplaceThroughputKeepsContent(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		replaced    map[string]interface{}
+		consistency []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consistency = append(consistency, r.Header.Get(HEADER_CONSISTENCY))
+		if r.Method == "POST" {
+			fmt.Fprint(w, `{"Offers": [{
+				"_self": "offers/abc/",
+				"offerVersion": "V2",
+				"offerMinimumThroughputParameters": {"maxThroughputEverProvisioned": 4000},
+				"content": {
+					"offerThroughput": 400,
+					"offerIsRUPerMinuteThroughputEnabled": false,
+					"offerAutopilotSettings": {"maxThroughput": 4000}
+				}
+			}]}`)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &replaced)
+		w.Write(b)
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	offer, err := c.ReplaceThroughput(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/", 1000, &RequestOptions{ConsistencyLevel: Strong})
+	assert.Nil(err)
+	// the options are given to both the offer query and its replace
+	assert.Equal([]string{"Strong", "Strong"}, consistency)
+	assert.Equal(1000, offer.Content.OfferThroughput)
+	assert.Equal(map[string]interface{}{
+		"offerThroughput":                     float64(1000),
+		"offerIsRUPerMinuteThroughputEnabled": false,
+		"offerAutopilotSettings":              map[string]interface{}{"maxThroughput": float64(4000)},
+	}, replaced["content"])
+	assert.Equal(map[string]interface{}{"maxThroughputEverProvisioned": float64(4000)}, replaced["offerMinimumThroughputParameters"])
+	assert.Equal("V2", replaced["offerVersion"])
+}
@@ -0,0 +1,134 @@
+package documentdb
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// RequestOptions are per-request settings, sent to the server as headers.
+type RequestOptions struct {
+	// PartitionKey is the partition key value of the target document(s).
+	// Document operations on a partitioned collection must specify it, the
+	// `Col` methods extract it from the document body when it's missing.
+	PartitionKey interface{}
+	// IfMatch and IfNoneMatch are the etag preconditions of the request.
+	IfMatch     string
+	IfNoneMatch string
+	// PreTriggers and PostTriggers are the ids of the triggers to run before
+	// and after a document write.
+	PreTriggers  []string
+	PostTriggers []string
+	// ConsistencyLevel overrides the consistency of a read. It can only be
+	// weaker than the account default consistency.
+	ConsistencyLevel ConsistencyLevel
+	// SessionToken of a read, overrides the session token captured by the
+	// client (see DocumentDB.SessionToken).
+	SessionToken string
+	// IndexingDirective overrides the indexing policy of the collection for
+	// a document write.
+	IndexingDirective IndexingDirective
+	// MaxItemCount is the max number of items per page of a query or a read
+	// feed. It's overridden by Query.MaxItems.
+	MaxItemCount int
+	// EnableScriptLogging enables the console.log of stored procedures.
+	EnableScriptLogging bool
+	// OfferThroughput is the provisioned throughput (RU/s) of a collection or
+	// a database on creation.
+	OfferThroughput int
+}
+
+// Return a copy of h with the options headers
+func (o *RequestOptions) headers(h map[string]string) (map[string]string, error) {
+	if o == nil {
+		return h, nil
+	}
+	m := make(map[string]string, len(h)+1)
+	for k, v := range h {
+		m[k] = v
+	}
+	h = m
+	if o.PartitionKey != nil {
+		b, err := json.Marshal([]interface{}{o.PartitionKey})
+		if err != nil {
+			return nil, err
+		}
+		h[HEADER_PARTITIONKEY] = string(b)
+	}
+	if o.IfMatch != "" {
+		h[HEADER_IF_MATCH] = o.IfMatch
+	}
+	if o.IfNoneMatch != "" {
+		h[HEADER_IF_NONE_MATCH] = o.IfNoneMatch
+	}
+	if len(o.PreTriggers) > 0 {
+		h[HEADER_PRE_TRIGGER] = strings.Join(o.PreTriggers, ",")
+	}
+	if len(o.PostTriggers) > 0 {
+		h[HEADER_POST_TRIGGER] = strings.Join(o.PostTriggers, ",")
+	}
+	if o.ConsistencyLevel != "" {
+		h[HEADER_CONSISTENCY] = string(o.ConsistencyLevel)
+	}
+	if o.SessionToken != "" {
+		h[HEADER_SESSION_TOKEN] = o.SessionToken
+	}
+	if o.IndexingDirective != "" {
+		h[HEADER_INDEXING_DIRECTIVE] = string(o.IndexingDirective)
+	}
+	if o.MaxItemCount > 0 {
+		h[HEADER_MAX_ITEMS] = strconv.Itoa(o.MaxItemCount)
+	}
+	if o.EnableScriptLogging {
+		h[HEADER_SCRIPT_LOGGING] = "true"
+	}
+	if o.OfferThroughput > 0 {
+		h[HEADER_OFFER_THROUGHPUT] = strconv.Itoa(o.OfferThroughput)
+	}
+	return h, nil
+}
+
+// Return the options of a variadic argument, or nil if not given
+func requestOptions(opts []*RequestOptions) *RequestOptions {
+	for i := len(opts) - 1; i >= 0; i-- {
+		if opts[i] != nil {
+			return opts[i]
+		}
+	}
+	return nil
+}
+
+// Headers of the given options merged into h
+func optionsHeaders(h map[string]string, opts []*RequestOptions) (map[string]string, error) {
+	return requestOptions(opts).headers(h)
+}
+
+// Undefined is the partition key value of documents that don't contain the
+// partition key path.
+var Undefined = struct{}{}
+
+// Extract the partition key value from the document using the collection
+// partition key path (e.g: "/address/city").
+func partitionKeyOf(doc interface{}, def *PartitionKeyDefinition) (interface{}, error) {
+	if def == nil || len(def.Paths) == 0 {
+		return nil, nil
+	}
+	b, err := stringify(doc)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Split(strings.Trim(def.Paths[0], "/"), "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return Undefined, nil
+		}
+		if v, ok = m[strings.Trim(p, `"`)]; !ok {
+			return Undefined, nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,141 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionKeyOf(t *testing.T) {
+	assert := assert.New(t)
+	type Address struct {
+		City string `json:"city"`
+	}
+	type User struct {
+		Document
+		Tenant  string  `json:"tenant"`
+		Address Address `json:"address"`
+	}
+	doc := &User{Tenant: "a8m", Address: Address{City: "Tel Aviv"}}
+
+	pk, err := partitionKeyOf(doc, &PartitionKeyDefinition{Paths: []string{"/tenant"}})
+	assert.Nil(err)
+	assert.Equal("a8m", pk)
+
+	pk, err = partitionKeyOf(doc, &PartitionKeyDefinition{Paths: []string{"/address/city"}})
+	assert.Nil(err)
+	assert.Equal("Tel Aviv", pk)
+
+	pk, err = partitionKeyOf(doc, &PartitionKeyDefinition{Paths: []string{"/missing/path"}})
+	assert.Nil(err)
+	assert.Equal(Undefined, pk)
+
+	pk, err = partitionKeyOf(doc, nil)
+	assert.Nil(err)
+	assert.Nil(pk)
+}
+
+func TestRequestOptionsHeaders(t *testing.T) {
+	assert := assert.New(t)
+	var o *RequestOptions
+	h, err := o.headers(nil)
+	assert.Nil(err)
+	assert.Nil(h)
+
+	o = &RequestOptions{PartitionKey: "foo"}
+	in := map[string]string{HEADER_IF_MATCH: "etag"}
+	h, err = o.headers(in)
+	assert.Nil(err)
+	assert.Equal(map[string]string{HEADER_IF_MATCH: "etag", HEADER_PARTITIONKEY: `["foo"]`}, h)
+	assert.Len(in, 1, "should not modify the given headers")
+
+	h, err = (&RequestOptions{PartitionKey: Undefined}).headers(nil)
+	assert.Nil(err)
+	assert.Equal(`[{}]`, h[HEADER_PARTITIONKEY])
+
+	h, err = (&RequestOptions{PreTriggers: []string{"validate", "stamp"}, PostTriggers: []string{"audit"}}).headers(nil)
+	assert.Nil(err)
+	assert.Equal(map[string]string{HEADER_PRE_TRIGGER: "validate,stamp", HEADER_POST_TRIGGER: "audit"}, h)
+
+	h, err = (&RequestOptions{
+		IfMatch:             "etag",
+		IfNoneMatch:         "*",
+		ConsistencyLevel:    Eventual,
+		SessionToken:        "0:-1#12",
+		IndexingDirective:   IndexingExclude,
+		MaxItemCount:        10,
+		EnableScriptLogging: true,
+		OfferThroughput:     400,
+	}).headers(map[string]string{HEADER_IF_MATCH: "old"})
+	assert.Nil(err)
+	assert.Equal(map[string]string{
+		HEADER_IF_MATCH:           "etag",
+		HEADER_IF_NONE_MATCH:      "*",
+		HEADER_CONSISTENCY:        "Eventual",
+		HEADER_SESSION_TOKEN:      "0:-1#12",
+		HEADER_INDEXING_DIRECTIVE: "Exclude",
+		HEADER_MAX_ITEMS:          "10",
+		HEADER_SCRIPT_LOGGING:     "true",
+		HEADER_OFFER_THROUGHPUT:   "400",
+	}, h)
+}
+
+func TestRequestOptionsArguments(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "coll"}`, `{"id": "db"}`, `{"StoredProcedures": [{"id": "sproc"}]}`)
+	s.SetStatus(http.StatusOK)
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	db := &DB{c: c, Database: Database{Resource: Resource{Self: "dbs/b5NCAA==/"}}}
+	ctx := context.Background()
+
+	coll, err := db.CreateCollection(ctx, "coll", nil, &RequestOptions{OfferThroughput: 1000})
+	assert.Nil(err)
+	assert.Equal("1000", s.Header.Get(HEADER_OFFER_THROUGHPUT))
+
+	_, err = c.ReadDatabase(ctx, "dbs/b5NCAA==/", &RequestOptions{IfNoneMatch: "etag"})
+	assert.Nil(err)
+	assert.Equal("etag", s.Header.Get(HEADER_IF_NONE_MATCH))
+
+	_, err = coll.Proc(ctx, "sproc", &RequestOptions{ConsistencyLevel: Eventual})
+	assert.Nil(err)
+	assert.Equal("Eventual", s.Header.Get(HEADER_CONSISTENCY))
+}
+
+func TestColPartitionKey(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"id": "1"}`, `{"id": "1"}`, `{"id": "1"}`)
+	s.SetStatus(http.StatusCreated)
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	coll := &Col{
+		db: &DB{c: c},
+		Collection: Collection{
+			Resource:     Resource{Self: "dbs/b5NCAA==/colls/b5NCAKzqWAA=/"},
+			PartitionKey: &PartitionKeyDefinition{Paths: []string{"/tenant"}, Kind: HashPartition},
+		},
+	}
+	ctx := context.Background()
+
+	// Extracted from the document
+	doc := struct {
+		Document
+		Tenant string `json:"tenant"`
+	}{Tenant: "a8m"}
+	_, err := coll.CreateDocument(ctx, &doc)
+	assert.Nil(err)
+	assert.NotEqual("", doc.Id)
+	assert.Equal(`["a8m"]`, s.Header.Get(HEADER_PARTITIONKEY))
+
+	// Explicit value
+	_, err = coll.CreateDocument(ctx, &doc, &RequestOptions{PartitionKey: 10})
+	assert.Nil(err)
+	assert.Equal(`[10]`, s.Header.Get(HEADER_PARTITIONKEY))
+
+	var out Document
+	err = coll.ReadDocumentByLink(ctx, "dbs/b5NCAA==/colls/b5NCAKzqWAA=/docs/b5NCAKzqWAABAAAAAAAAAA==/", &out, &RequestOptions{PartitionKey: "a8m"})
+	assert.Nil(err)
+	assert.Equal(`["a8m"]`, s.Header.Get(HEADER_PARTITIONKEY))
+}
@@ -0,0 +1,209 @@
+// Package oteldocumentdb instruments a DocumentDB client with OpenTelemetry.
+//
+// Each call to the client emits a span, and records its latency and request
+// charge in histograms.
+//
+// Example:
+//
+//	client := documentdb.NewWithClient(oteldocumentdb.Wrap(&documentdb.Client{
+//		Url:    url,
+//		Config: documentdb.Config{MasterKey: key},
+//	}))
+package oteldocumentdb
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/a8m/documentdb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/a8m/documentdb/oteldocumentdb"
+
+// Attribute keys of the spans and the metrics
+const (
+	OperationKey     = attribute.Key("documentdb.operation")
+	ResourceTypeKey  = attribute.Key("documentdb.resource_type")
+	CollectionKey    = attribute.Key("documentdb.collection")
+	SprocKey         = attribute.Key("documentdb.sproc")
+	QueryKey         = attribute.Key("db.statement")
+	StatusCodeKey    = attribute.Key("http.status_code")
+	RequestChargeKey = attribute.Key("documentdb.request_charge")
+	RetryCountKey    = attribute.Key("documentdb.retry_count")
+	ActivityIdKey    = attribute.Key("documentdb.activity_id")
+)
+
+// Option configures the instrumentation
+type Option func(*client)
+
+// WithTracerProvider sets the tracer provider, defaults to the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *client) {
+		c.tp = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider, defaults to the global one.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *client) {
+		c.mp = mp
+	}
+}
+
+type client struct {
+	next     documentdb.Clienter
+	tp       trace.TracerProvider
+	mp       metric.MeterProvider
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	charge   metric.Float64Histogram
+}
+
+// Wrap returns a Clienter that instruments the calls of the given one.
+func Wrap(next documentdb.Clienter, opts ...Option) documentdb.Clienter {
+	c := &client{next: next, tp: otel.GetTracerProvider(), mp: otel.GetMeterProvider()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.tracer = c.tp.Tracer(instrumentationName)
+	meter := c.mp.Meter(instrumentationName)
+	var err error
+	if c.duration, err = meter.Float64Histogram(
+		"documentdb.client.duration",
+		metric.WithDescription("Duration of the DocumentDB calls"),
+		metric.WithUnit("s"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if c.charge, err = meter.Float64Histogram(
+		"documentdb.client.request_charge",
+		metric.WithDescription("Request charge of the DocumentDB calls"),
+		metric.WithUnit("{RU}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	return c
+}
+
+// Instrument a call
+func (c *client) observe(ctx context.Context, op, link string, qu *documentdb.Query, call func(context.Context) error) error {
+	attrs := []attribute.KeyValue{
+		OperationKey.String(op),
+		ResourceTypeKey.String(documentdb.ResourceRequest(link, nil).ResourceType()),
+	}
+	if coll := documentdb.CtxCollection(ctx); coll != "" {
+		attrs = append(attrs, CollectionKey.String(coll))
+	}
+	if sproc := documentdb.CtxSproc(ctx); sproc != "" {
+		attrs = append(attrs, SprocKey.String(sproc))
+	}
+	ctx, span := c.tracer.Start(ctx, "documentdb."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+	if qu == nil {
+		qu = documentdb.CtxQuery(ctx)
+	}
+	if qu != nil && qu.Text != "" {
+		span.SetAttributes(QueryKey.String(qu.Text))
+	}
+
+	var info documentdb.ResponseInfo
+	start := time.Now()
+	err := call(documentdb.WithResponseInfo(ctx, &info))
+	elapsed := time.Since(start)
+
+	// the status code is also an attribute of the metrics
+	if info.StatusCode != 0 {
+		attrs = append(attrs, StatusCodeKey.Int(info.StatusCode))
+		span.SetAttributes(StatusCodeKey.Int(info.StatusCode))
+	}
+	span.SetAttributes(
+		RequestChargeKey.Float64(info.RequestCharge),
+		RetryCountKey.Int(info.RetryCount),
+	)
+	if info.ActivityId != "" {
+		span.SetAttributes(ActivityIdKey.String(info.ActivityId))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	set := metric.WithAttributes(attrs...)
+	if c.duration != nil {
+		c.duration.Record(ctx, elapsed.Seconds(), set)
+	}
+	if c.charge != nil {
+		c.charge.Record(ctx, info.RequestCharge, set)
+	}
+	return err
+}
+
+func (c *client) Query(ctx context.Context, link string, qu *documentdb.Query, ret interface{}, headers map[string]string) (token string, err error) {
+	err = c.observe(ctx, "query", link, qu, func(ctx context.Context) (err error) {
+		token, err = c.next.Query(ctx, link, qu, ret, headers)
+		return
+	})
+	return
+}
+
+func (c *client) Create(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error {
+	return c.observe(ctx, "create", link, nil, func(ctx context.Context) error {
+		return c.next.Create(ctx, link, body, ret, headers)
+	})
+}
+
+func (c *client) Replace(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error {
+	return c.observe(ctx, "replace", link, nil, func(ctx context.Context) error {
+		return c.next.Replace(ctx, link, body, ret, headers)
+	})
+}
+
+func (c *client) Delete(ctx context.Context, link string, headers map[string]string) error {
+	return c.observe(ctx, "delete", link, nil, func(ctx context.Context) error {
+		return c.next.Delete(ctx, link, headers)
+	})
+}
+
+func (c *client) Execute(ctx context.Context, link string, body, ret interface{}, headers map[string]string) error {
+	return c.observe(ctx, "execute", link, nil, func(ctx context.Context) error {
+		return c.next.Execute(ctx, link, body, ret, headers)
+	})
+}
+
+func (c *client) Upload(ctx context.Context, link string, media io.Reader, ret interface{}, headers map[string]string) error {
+	return c.observe(ctx, "upload", link, nil, func(ctx context.Context) error {
+		return c.next.Upload(ctx, link, media, ret, headers)
+	})
+}
+
+// The span of a download ends when the response headers are received
+func (c *client) Download(ctx context.Context, link string, headers map[string]string) (rc io.ReadCloser, err error) {
+	err = c.observe(ctx, "download", link, nil, func(ctx context.Context) (err error) {
+		rc, err = c.next.Download(ctx, link, headers)
+		return
+	})
+	return
+}
+
+// SessionToken returns the session token of the wrapped client
+func (c *client) SessionToken(coll string) string {
+	if s, ok := c.next.(interface{ SessionToken(string) string }); ok {
+		return s.SessionToken(coll)
+	}
+	return ""
+}
+
+// SetSessionToken merges the token into the session of the wrapped client
+func (c *client) SetSessionToken(coll, token string) {
+	if s, ok := c.next.(interface{ SetSessionToken(string, string) }); ok {
+		s.SetSessionToken(coll, token)
+	}
+}
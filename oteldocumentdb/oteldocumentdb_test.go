@@ -0,0 +1,108 @@
+package oteldocumentdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a8m/documentdb"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWrap(t *testing.T) {
+	assert := assert.New(t)
+	status := []int{http.StatusTooManyRequests, http.StatusOK, http.StatusNotFound}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(documentdb.HEADER_CHARGE, "2.5")
+		w.Header().Set(documentdb.HEADER_ACTIVITY_ID, "a1b2")
+		w.WriteHeader(status[0])
+		status = status[1:]
+		w.Write([]byte(`{"Documents": [{"id": "1"}]}`))
+	}))
+	defer s.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	c := documentdb.NewWithClient(Wrap(&documentdb.Client{
+		Url:    s.URL,
+		Config: documentdb.Config{MasterKey: "YXJpZWwNCg==", MaxRetries: 1},
+	}, WithTracerProvider(tp), WithMeterProvider(mp)))
+
+	ctx := context.Background()
+	var docs []documentdb.Document
+	_, err := c.QueryDocuments(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/", documentdb.NewQuery("SELECT * FROM c", nil), &docs)
+	assert.Nil(err)
+	err = c.DeleteDocument(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", "")
+	assert.NotNil(err)
+
+	ended := spans.Ended()
+	assert.Len(ended, 2)
+	query := attribute.NewSet(ended[0].Attributes()...)
+	assert.Equal("documentdb.query", ended[0].Name())
+	v, _ := query.Value(OperationKey)
+	assert.Equal("query", v.AsString())
+	v, _ = query.Value(ResourceTypeKey)
+	assert.Equal("docs", v.AsString())
+	v, _ = query.Value(QueryKey)
+	assert.Equal("SELECT * FROM c", v.AsString())
+	v, _ = query.Value(RequestChargeKey)
+	assert.Equal(5.0, v.AsFloat64())
+	v, _ = query.Value(RetryCountKey)
+	assert.Equal(int64(1), v.AsInt64())
+	v, _ = query.Value(StatusCodeKey)
+	assert.Equal(int64(200), v.AsInt64())
+	v, _ = query.Value(ActivityIdKey)
+	assert.Equal("a1b2", v.AsString())
+	assert.Equal(codes.Unset, ended[0].Status().Code)
+
+	assert.Equal("documentdb.delete", ended[1].Name())
+	assert.Equal(codes.Error, ended[1].Status().Code)
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(reader.Collect(ctx, &rm))
+	assert.Len(rm.ScopeMetrics, 1)
+	names := map[string]uint64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		var count uint64
+		for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+			count += dp.Count
+		}
+		names[m.Name] = count
+	}
+	assert.Equal(map[string]uint64{"documentdb.client.duration": 2, "documentdb.client.request_charge": 2}, names)
+}
+
+func TestWrapSession(t *testing.T) {
+	assert := assert.New(t)
+	var tokens []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get(documentdb.HEADER_SESSION_TOKEN))
+		w.Header().Set(documentdb.HEADER_SESSION_TOKEN, "0:-1#12")
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+	c := documentdb.NewWithClient(Wrap(&documentdb.Client{
+		Url:    s.URL,
+		Config: documentdb.Config{MasterKey: "YXJpZWwNCg=="},
+	}))
+	ctx := context.Background()
+	coll := "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"
+	var doc documentdb.Document
+	assert.Nil(c.ReadDocument(ctx, coll+"docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc))
+	assert.Equal("0:-1#12", c.SessionToken(coll))
+
+	c.SetSessionToken(coll, "1:-1#7")
+	assert.Equal("0:-1#12,1:-1#7", c.SessionToken(coll))
+	assert.Nil(c.ReadDocument(ctx, coll+"docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc))
+	assert.Equal([]string{"", "0:-1#12,1:-1#7"}, tokens)
+}
@@ -0,0 +1,456 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errLeaseLost = errors.New("lease lost")
+)
+
+// Lease of a feed partition key range, stored as a document in the lease
+// collection.
+type Lease struct {
+	Document
+	RangeId    string `json:"rangeId"`
+	Owner      string `json:"owner,omitempty"`
+	Checkpoint string `json:"checkpoint,omitempty"` // etag of the next change
+	Timestamp  int64  `json:"timestamp,omitempty"`  // last renewal, in unix milliseconds
+}
+
+// Return true if nobody holds the lease
+func (l *Lease) expired(now time.Time, d time.Duration) bool {
+	return l.Owner == "" || now.Sub(time.Unix(0, l.Timestamp*int64(time.Millisecond))) > d
+}
+
+// ChangeFeedHandler handles a batch of changed documents. The batch is
+// checkpointed only if the handler returns without an error, otherwise it's
+// delivered again.
+type ChangeFeedHandler func(ctx context.Context, docs []json.RawMessage) error
+
+// ChangeFeedProcessorOptions controls the leases and the reading of the feed.
+type ChangeFeedProcessorOptions struct {
+	// Name identifies the processor instance, and used as the lease owner.
+	// Defaults to a random uuid.
+	Name string
+	// LeasePrefix is the prefix of the lease documents ids. It allows multiple
+	// processors to share the same lease collection.
+	LeasePrefix string
+	// LeaseExpiration is the duration after which a lease that wasn't renewed
+	// can be taken by another instance. Defaults to 60 seconds.
+	LeaseExpiration time.Duration
+	// LeaseRenewInterval must be shorter than LeaseExpiration. Defaults to
+	// 17 seconds.
+	LeaseRenewInterval time.Duration
+	// LeaseAcquireInterval is the interval of the leases balancing.
+	// Defaults to 13 seconds.
+	LeaseAcquireInterval time.Duration
+	// FeedPollDelay is the delay between polls of a caught up range, or after
+	// a failure. Defaults to 5 seconds.
+	FeedPollDelay time.Duration
+	// PageSize is the max number of changes in a batch.
+	PageSize int
+	// StartFromBeginning reads the feed from its beginning when a lease has
+	// no checkpoint yet.
+	StartFromBeginning bool
+	// OnError is called with the errors that the processor recovers from.
+	OnError func(err error)
+}
+
+// ChangeFeedProcessor reads the change feed of a collection and calls the
+// handler with batches of changes. The partition key ranges of the feed are
+// distributed between all the processor instances that share the lease
+// collection, using a lease document per range.
+//
+// Example:
+//
+//	p := NewChangeFeedProcessor(coll, leases, func(ctx context.Context, docs []json.RawMessage) error {
+//		// ...
+//	}, ChangeFeedProcessorOptions{Name: hostname})
+//	if err := p.Run(ctx); err != nil {
+//		// ...
+//	}
+type ChangeFeedProcessor struct {
+	feed    *Col
+	leases  *Col
+	handler ChangeFeedHandler
+	opts    ChangeFeedProcessorOptions
+	mu      sync.Mutex
+	workers map[string]*leaseWorker
+	wg      sync.WaitGroup
+}
+
+// Worker of an owned lease
+type leaseWorker struct {
+	mu     sync.Mutex // serialize the lease updates
+	lease  *Lease
+	cancel context.CancelFunc
+}
+
+// NewChangeFeedProcessor creates a processor for the feed collection that
+// stores its leases in the leases collection. The leases collection should be
+// unpartitioned, or partitioned by "/id".
+func NewChangeFeedProcessor(feed, leases *Col, handler ChangeFeedHandler, opts ChangeFeedProcessorOptions) *ChangeFeedProcessor {
+	if opts.Name == "" {
+		opts.Name = uuid()
+	}
+	if opts.LeaseExpiration == 0 {
+		opts.LeaseExpiration = 60 * time.Second
+	}
+	if opts.LeaseRenewInterval == 0 {
+		opts.LeaseRenewInterval = 17 * time.Second
+	}
+	if opts.LeaseAcquireInterval == 0 {
+		opts.LeaseAcquireInterval = 13 * time.Second
+	}
+	if opts.FeedPollDelay == 0 {
+		opts.FeedPollDelay = 5 * time.Second
+	}
+	return &ChangeFeedProcessor{
+		feed:    feed,
+		leases:  leases,
+		handler: handler,
+		opts:    opts,
+		workers: make(map[string]*leaseWorker),
+	}
+}
+
+// Run the processor until the context is canceled. The owned leases are
+// released on return, so other instances can take them immediately.
+func (p *ChangeFeedProcessor) Run(ctx context.Context) error {
+	if p.opts.LeaseRenewInterval >= p.opts.LeaseExpiration {
+		return fmt.Errorf("lease renew interval (%v) must be shorter than the lease expiration (%v)", p.opts.LeaseRenewInterval, p.opts.LeaseExpiration)
+	}
+	if err := p.syncLeases(ctx); err != nil {
+		return err
+	}
+	p.acquire(ctx)
+	acquire := time.NewTicker(p.opts.LeaseAcquireInterval)
+	defer acquire.Stop()
+	renew := time.NewTicker(p.opts.LeaseRenewInterval)
+	defer renew.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.release()
+			return nil
+		case <-acquire.C:
+			if err := p.syncLeases(ctx); err != nil {
+				p.error(err)
+			}
+			p.acquire(ctx)
+		case <-renew.C:
+			p.renew(ctx)
+		}
+	}
+}
+
+// Owned returns the partition key ranges that this instance holds
+func (p *ChangeFeedProcessor) Owned() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.workers))
+	for id := range p.workers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (p *ChangeFeedProcessor) error(err error) {
+	if p.opts.OnError != nil && err != nil {
+		p.opts.OnError(err)
+	}
+}
+
+// Read all the leases of the processor
+func (p *ChangeFeedProcessor) listLeases(ctx context.Context) ([]*Lease, error) {
+	q := NewQuery("SELECT * FROM root r WHERE STARTSWITH(r.id, @prefix)", map[string]interface{}{"@prefix": p.opts.LeasePrefix})
+	var leases []*Lease
+	if p.leases.PartitionKey != nil {
+		err := p.leases.QueryDocumentsCrossPartition(ctx, q, &leases)
+		return leases, err
+	}
+	it := p.leases.IterateDocuments(q, IteratorOptions{})
+	defer it.Close()
+	for it.Next(ctx) {
+		var l Lease
+		if err := it.Decode(&l); err != nil {
+			return nil, err
+		}
+		leases = append(leases, &l)
+	}
+	return leases, it.Err()
+}
+
+// Create a lease for each new partition key range of the feed, and remove the
+// leases of ranges that are gone (i.e: split). New ranges start from the
+// checkpoint of their parent.
+func (p *ChangeFeedProcessor) syncLeases(ctx context.Context) error {
+	ranges, err := p.feed.db.c.ReadPartitionKeyRanges(p.feed.ctx(ctx), p.feed.Self)
+	if err != nil {
+		return err
+	}
+	leases, err := p.listLeases(ctx)
+	if err != nil {
+		return err
+	}
+	byRange := make(map[string]*Lease, len(leases))
+	for _, l := range leases {
+		byRange[l.RangeId] = l
+	}
+	live := make(map[string]bool, len(ranges))
+	for _, r := range ranges {
+		live[r.Id] = true
+		if _, ok := byRange[r.Id]; ok {
+			continue
+		}
+		l := &Lease{RangeId: r.Id}
+		l.Id = p.opts.LeasePrefix + r.Id
+		for i := len(r.Parents) - 1; i >= 0; i-- {
+			if parent, ok := byRange[r.Parents[i]]; ok {
+				l.Checkpoint = parent.Checkpoint
+				break
+			}
+		}
+		if _, err := p.leases.CreateDocument(ctx, l); err != nil && !IsExists(err) {
+			return err
+		}
+	}
+	for _, l := range leases {
+		if live[l.RangeId] {
+			continue
+		}
+		p.stop(l.RangeId, nil)
+		o, err := p.leases.docOptions(l, nil)
+		if err != nil {
+			return err
+		}
+		if err := p.leases.DeleteDocumentByLink(ctx, l.Self, "", o); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Take expired leases, or steal one, to get an equal share of the leases
+func (p *ChangeFeedProcessor) acquire(ctx context.Context) {
+	leases, err := p.listLeases(ctx)
+	if err != nil {
+		p.error(err)
+		return
+	}
+	now := time.Now()
+	for _, l := range leases {
+		p.mu.Lock()
+		_, running := p.workers[l.RangeId]
+		p.mu.Unlock()
+		switch {
+		// restarted with the same name
+		case !running && l.Owner == p.opts.Name && !l.expired(now, p.opts.LeaseExpiration):
+			p.take(ctx, l)
+		// taken by another instance
+		case running && l.Owner != p.opts.Name:
+			p.stop(l.RangeId, nil)
+		}
+	}
+	for _, l := range leasesToTake(leases, p.opts.Name, now, p.opts.LeaseExpiration) {
+		p.take(ctx, l)
+	}
+}
+
+// Return the leases that the owner should take in order to hold an equal
+// share of all leases. Expired leases are taken first, if there aren't enough
+// of them, a single lease is stolen from the owner with most leases.
+func leasesToTake(leases []*Lease, owner string, now time.Time, expiration time.Duration) []*Lease {
+	var (
+		expired []*Lease
+		owned   = map[string][]*Lease{owner: nil}
+	)
+	for _, l := range leases {
+		if l.expired(now, expiration) {
+			expired = append(expired, l)
+		} else {
+			owned[l.Owner] = append(owned[l.Owner], l)
+		}
+	}
+	target := (len(leases) + len(owned) - 1) / len(owned)
+	n := target - len(owned[owner])
+	if n <= 0 {
+		return nil
+	}
+	if len(expired) > 0 {
+		if n > len(expired) {
+			n = len(expired)
+		}
+		return expired[:n]
+	}
+	var max string
+	for o, ls := range owned {
+		if len(ls) > len(owned[max]) || len(ls) == len(owned[max]) && o < max {
+			max = o
+		}
+	}
+	if len(owned[max]) > target {
+		return owned[max][:1]
+	}
+	return nil
+}
+
+// Take the lease and start processing its range
+func (p *ChangeFeedProcessor) take(ctx context.Context, l *Lease) {
+	w := &leaseWorker{lease: l}
+	err := p.update(ctx, w, func(l *Lease) {
+		l.Owner = p.opts.Name
+	})
+	if err != nil {
+		if err != errLeaseLost {
+			p.error(err)
+		}
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.workers[l.RangeId]; ok {
+		return
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+	p.workers[l.RangeId] = w
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.process(wctx, w)
+	}()
+}
+
+// Stop processing the range of the lease. If a worker is given, it's stopped
+// only if it still processes the range (i.e: the lease wasn't taken again
+// since).
+func (p *ChangeFeedProcessor) stop(rangeId string, w *leaseWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cur, ok := p.workers[rangeId]; ok && (w == nil || cur == w) {
+		cur.cancel()
+		delete(p.workers, rangeId)
+	}
+}
+
+// Renew all the owned leases
+func (p *ChangeFeedProcessor) renew(ctx context.Context) {
+	p.mu.Lock()
+	workers := make(map[string]*leaseWorker, len(p.workers))
+	for id, w := range p.workers {
+		workers[id] = w
+	}
+	p.mu.Unlock()
+	for id, w := range workers {
+		if err := p.update(ctx, w, func(*Lease) {}); err == errLeaseLost {
+			p.stop(id, w)
+		} else if err != nil {
+			p.error(err)
+		}
+	}
+}
+
+// Stop all workers and release their leases
+func (p *ChangeFeedProcessor) release() {
+	p.mu.Lock()
+	workers := p.workers
+	p.workers = make(map[string]*leaseWorker)
+	for _, w := range workers {
+		w.cancel()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.LeaseRenewInterval)
+	defer cancel()
+	for _, w := range workers {
+		if err := p.update(ctx, w, func(l *Lease) { l.Owner = "" }); err != nil && err != errLeaseLost {
+			p.error(err)
+		}
+	}
+}
+
+// Update the lease, unless it was changed since it was read. In that case,
+// the update is retried only if the lease is still owned by the processor,
+// otherwise (i.e: owned by another instance, or released) errLeaseLost is
+// returned.
+func (p *ChangeFeedProcessor) update(ctx context.Context, w *leaseWorker, fn func(*Lease)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		l := *w.lease
+		fn(&l)
+		l.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		doc, err := p.leases.ReplaceDocumentByLink(ctx, l.Self, &l, w.lease.Etag)
+		if err == nil {
+			l.Etag = doc.Etag
+			w.lease = &l
+			return nil
+		}
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+		// changed since, retry if nobody else took it
+		var cur Lease
+		o, err := p.leases.docOptions(&l, nil)
+		if err != nil {
+			return err
+		}
+		if err := p.leases.ReadDocumentByLink(ctx, l.Self, &cur, o); err != nil {
+			return err
+		}
+		if cur.Owner != p.opts.Name {
+			return errLeaseLost
+		}
+		w.lease = &cur
+	}
+	return errLeaseLost
+}
+
+// Read the changes of the lease range and checkpoint them after they handled
+func (p *ChangeFeedProcessor) process(ctx context.Context, w *leaseWorker) {
+	for ctx.Err() == nil {
+		w.mu.Lock()
+		rangeId, etag := w.lease.RangeId, w.lease.Checkpoint
+		w.mu.Unlock()
+		if etag == "" && !p.opts.StartFromBeginning {
+			etag = "*"
+		}
+		docs, next, err := p.feed.db.c.readChanges(p.feed.ctx(ctx), p.feed.Self, rangeId, etag, p.opts.PageSize)
+		if err == nil && len(docs) > 0 {
+			err = p.handler(ctx, docs)
+		}
+		if err == nil && next != "" && next != etag {
+			if err = p.update(ctx, w, func(l *Lease) { l.Checkpoint = next }); err == errLeaseLost {
+				p.stop(rangeId, w)
+				return
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			p.error(err)
+		}
+		if err != nil || len(docs) == 0 {
+			sleep(ctx, p.opts.FeedPollDelay)
+		}
+	}
+}
+
+// Sleep for the given duration, or until the context is canceled
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
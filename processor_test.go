@@ -0,0 +1,325 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasesToTake(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	ts := now.UnixNano() / int64(time.Millisecond)
+	old := now.Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+	lease := func(id, owner string, ts int64) *Lease {
+		return &Lease{RangeId: id, Owner: owner, Timestamp: ts}
+	}
+	ids := func(leases []*Lease) (ids []string) {
+		for _, l := range leases {
+			ids = append(ids, l.RangeId)
+		}
+		return
+	}
+
+	// Take all free leases when alone
+	leases := []*Lease{lease("0", "", 0), lease("1", "", 0), lease("2", "b", old)}
+	assert.Equal([]string{"0", "1", "2"}, ids(leasesToTake(leases, "a", now, time.Minute)))
+
+	// Equal share with another live instance
+	leases = []*Lease{lease("0", "b", ts), lease("1", "", 0), lease("2", "", 0), lease("3", "b", old)}
+	assert.Equal([]string{"1", "2"}, ids(leasesToTake(leases, "a", now, time.Minute)))
+
+	// Steal a single lease from the owner with most leases
+	leases = []*Lease{lease("0", "b", ts), lease("1", "b", ts), lease("2", "b", ts), lease("3", "c", ts)}
+	assert.Equal([]string{"0"}, ids(leasesToTake(leases, "a", now, time.Minute)))
+
+	// Balanced
+	leases = []*Lease{lease("0", "a", ts), lease("1", "b", ts), lease("2", "c", ts)}
+	assert.Empty(leasesToTake(leases, "a", now, time.Minute))
+}
+
+func TestLeaseExpired(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	l := &Lease{Owner: "a", Timestamp: now.Add(-time.Second).UnixNano() / int64(time.Millisecond)}
+	assert.False(l.expired(now, time.Minute))
+	assert.True(l.expired(now.Add(time.Minute), time.Minute))
+	assert.True((&Lease{}).expired(now, time.Minute))
+}
+
+// fakeLeases serves the feed collection "feed" and an unpartitioned lease
+// collection "leases", with etag checks on the lease replaces
+type fakeLeases struct {
+	sync.Mutex
+	leases map[string]*Lease
+	etag   int
+	// range id => pages of changes, each page is served with the etag of its index
+	feeds map[string][]string
+}
+
+func newFakeLeases(feeds map[string][]string) (*fakeLeases, *httptest.Server) {
+	f := &fakeLeases{leases: make(map[string]*Lease), feeds: feeds}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/pkranges"):
+		var ranges []string
+		for id := range f.feeds {
+			ranges = append(ranges, fmt.Sprintf(`{"id": %q}`, id))
+		}
+		fmt.Fprintf(w, `{"PartitionKeyRanges": [%s]}`, strings.Join(ranges, ","))
+	case r.Header.Get(HEADER_A_IM) != "":
+		id, etag := r.Header.Get(HEADER_PKRANGE_ID), r.Header.Get(HEADER_IF_NONE_MATCH)
+		i := 0
+		fmt.Sscan(etag, &i)
+		if i >= len(f.feeds[id]) {
+			w.Header().Set(HEADER_ETAG, etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(HEADER_ETAG, fmt.Sprint(i+1))
+		fmt.Fprintf(w, `{"Documents": %s}`, f.feeds[id][i])
+	case r.Header.Get(HEADER_IS_QUERY) != "":
+		leases := make([]*Lease, 0, len(f.leases))
+		for _, l := range f.leases {
+			leases = append(leases, l)
+		}
+		sort.Slice(leases, func(i, j int) bool { return leases[i].Id < leases[j].Id })
+		json.NewEncoder(w).Encode(map[string]interface{}{"Documents": leases})
+	case r.Method == "POST":
+		var l Lease
+		json.NewDecoder(r.Body).Decode(&l)
+		if _, ok := f.leases[l.Id]; ok {
+			http.Error(w, `{"code": "Conflict", "message": "Resource with specified id already exists"}`, http.StatusConflict)
+			return
+		}
+		l.Self = "dbs/db/colls/leases/docs/" + l.Id + "/"
+		f.put(w, &l)
+	default:
+		id := path[strings.LastIndex(path, "/")+1:]
+		l, ok := f.leases[id]
+		switch {
+		case !ok:
+			http.Error(w, `{"code": "NotFound", "message": "Resource Not Found"}`, http.StatusNotFound)
+		case r.Method == "GET":
+			json.NewEncoder(w).Encode(l)
+		case r.Method == "DELETE":
+			delete(f.leases, id)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Header.Get(HEADER_IF_MATCH) != l.Etag:
+			http.Error(w, `{"code": "PreconditionFailed", "message": "etag mismatch"}`, http.StatusPreconditionFailed)
+		default:
+			var l Lease
+			json.NewDecoder(r.Body).Decode(&l)
+			f.put(w, &l)
+		}
+	}
+}
+
+// Store the lease with a new etag, the lock must be held
+func (f *fakeLeases) put(w http.ResponseWriter, l *Lease) {
+	f.etag++
+	l.Etag = fmt.Sprint("etag", f.etag)
+	f.leases[l.Id] = l
+	json.NewEncoder(w).Encode(l)
+}
+
+// Return a copy of the stored lease
+func (f *fakeLeases) get(id string) Lease {
+	f.Lock()
+	defer f.Unlock()
+	if l, ok := f.leases[id]; ok {
+		return *l
+	}
+	return Lease{}
+}
+
+// Store the lease as if it was updated by another instance
+func (f *fakeLeases) set(l Lease) {
+	f.Lock()
+	defer f.Unlock()
+	f.etag++
+	l.Etag = fmt.Sprint("etag", f.etag)
+	f.leases[l.Id] = &l
+}
+
+func newTestProcessor(url string, handler ChangeFeedHandler, opts ChangeFeedProcessorOptions) *ChangeFeedProcessor {
+	db := &DB{c: New(url, Config{MasterKey: "YXJpZWwNCg=="}), Database: Database{Resource: Resource{Id: "db", Self: "dbs/db/"}}}
+	feed := &Col{db: db, Collection: Collection{Resource: Resource{Id: "feed", Self: "dbs/db/colls/feed/"}}}
+	leases := &Col{db: db, Collection: Collection{Resource: Resource{Id: "leases", Self: "dbs/db/colls/leases/"}}}
+	if opts.Name == "" {
+		opts.Name = "a"
+	}
+	opts.LeaseAcquireInterval = 10 * time.Millisecond
+	opts.LeaseRenewInterval = 10 * time.Millisecond
+	opts.FeedPollDelay = time.Millisecond
+	opts.StartFromBeginning = true
+	return NewChangeFeedProcessor(feed, leases, handler, opts)
+}
+
+func TestChangeFeedProcessorRun(t *testing.T) {
+	assert := assert.New(t)
+	f, s := newFakeLeases(map[string][]string{
+		"0": {`[{"id": "1"}, {"id": "2"}]`, `[{"id": "3"}]`},
+		"1": {`[{"id": "4"}]`},
+	})
+	defer s.Close()
+	var (
+		mu   sync.Mutex
+		ids  []string
+		fail = true
+	)
+	p := newTestProcessor(s.URL, func(ctx context.Context, docs []json.RawMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		var batch []Document
+		for _, b := range docs {
+			var doc Document
+			json.Unmarshal(b, &doc)
+			batch = append(batch, doc)
+		}
+		// the failed batch is delivered again
+		if batch[0].Id == "3" && fail {
+			fail = false
+			return errors.New("handler error")
+		}
+		for _, doc := range batch {
+			ids = append(ids, doc.Id)
+		}
+		return nil
+	}, ChangeFeedProcessorOptions{LeasePrefix: "p.", OnError: func(error) {}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	assert.Eventually(func() bool {
+		return f.get("p.0").Checkpoint == "2" && f.get("p.1").Checkpoint == "1"
+	}, time.Second, time.Millisecond, "checkpoint the handled batches")
+	assert.Equal([]string{"0", "1"}, p.Owned())
+	mu.Lock()
+	sort.Strings(ids)
+	assert.Equal([]string{"1", "2", "3", "4"}, ids)
+	mu.Unlock()
+	assert.Equal("a", f.get("p.0").Owner)
+
+	cancel()
+	assert.Nil(<-done)
+	assert.Empty(p.Owned())
+	assert.Equal("", f.get("p.0").Owner, "release the leases on return")
+	assert.Equal("", f.get("p.1").Owner)
+	assert.Equal("2", f.get("p.0").Checkpoint)
+}
+
+func TestChangeFeedProcessorAcquire(t *testing.T) {
+	assert := assert.New(t)
+	f, s := newFakeLeases(nil)
+	defer s.Close()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	old := time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+	lease := func(id, owner string, ts int64) Lease {
+		l := Lease{RangeId: id, Owner: owner, Timestamp: ts, Checkpoint: "9"}
+		l.Id, l.Self = id, "dbs/db/colls/leases/docs/"+id+"/"
+		return l
+	}
+	f.set(lease("0", "b", now))
+	f.set(lease("1", "b", now))
+	f.set(lease("2", "b", now))
+	p := newTestProcessor(s.URL, func(context.Context, []json.RawMessage) error { return nil }, ChangeFeedProcessorOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// steal a single lease from the busiest instance
+	p.acquire(ctx)
+	assert.Equal([]string{"0"}, p.Owned())
+	assert.Equal("a", f.get("0").Owner)
+	assert.Equal("9", f.get("0").Checkpoint, "keep the checkpoint of the lease")
+
+	// take the expired leases
+	f.set(lease("1", "b", old))
+	p.acquire(ctx)
+	assert.Equal([]string{"0", "1"}, p.Owned())
+
+	// the lease was taken by another instance
+	f.set(lease("1", "c", now))
+	p.acquire(ctx)
+	assert.Equal([]string{"0"}, p.Owned())
+	assert.Equal("c", f.get("1").Owner)
+
+	// lost on renewal
+	l := f.get("0")
+	l.Owner = "c"
+	f.set(l)
+	p.renew(ctx)
+	assert.Empty(p.Owned())
+	assert.Equal("c", f.get("0").Owner)
+
+	cancel()
+	p.release()
+	assert.Equal("c", f.get("0").Owner, "don't release a lost lease")
+}
+
+func TestChangeFeedProcessorUpdate(t *testing.T) {
+	assert := assert.New(t)
+	f, s := newFakeLeases(nil)
+	defer s.Close()
+	p := newTestProcessor(s.URL, nil, ChangeFeedProcessorOptions{})
+	ctx := context.Background()
+	l := Lease{RangeId: "0", Owner: "a"}
+	l.Id, l.Self = "0", "dbs/db/colls/leases/docs/0/"
+	f.set(l)
+	l = f.get("0")
+	w := &leaseWorker{lease: &l}
+
+	assert.Nil(p.update(ctx, w, func(l *Lease) { l.Checkpoint = "1" }))
+	assert.Equal("1", f.get("0").Checkpoint)
+	assert.Equal(f.get("0").Etag, w.lease.Etag)
+
+	// changed since, but still owned
+	cur := f.get("0")
+	cur.Timestamp = 1
+	f.set(cur)
+	assert.Nil(p.update(ctx, w, func(l *Lease) { l.Checkpoint = "2" }))
+	assert.Equal("2", f.get("0").Checkpoint)
+
+	// released by another instance
+	cur = f.get("0")
+	cur.Owner = ""
+	f.set(cur)
+	assert.Equal(errLeaseLost, p.update(ctx, w, func(l *Lease) { l.Checkpoint = "3" }))
+	assert.Equal("2", f.get("0").Checkpoint)
+}
+
+func TestChangeFeedProcessorStop(t *testing.T) {
+	assert := assert.New(t)
+	p := NewChangeFeedProcessor(nil, nil, nil, ChangeFeedProcessorOptions{})
+	var canceled []string
+	older := &leaseWorker{cancel: func() { canceled = append(canceled, "older") }}
+	newer := &leaseWorker{cancel: func() { canceled = append(canceled, "newer") }}
+	p.workers["0"] = newer
+	p.stop("0", older)
+	assert.Equal([]string{"0"}, p.Owned(), "a newer worker of the range keeps running")
+	assert.Empty(canceled)
+	p.stop("0", newer)
+	assert.Empty(p.Owned())
+	assert.Equal([]string{"newer"}, canceled)
+}
+
+func TestChangeFeedProcessorOptions(t *testing.T) {
+	p := NewChangeFeedProcessor(nil, nil, nil, ChangeFeedProcessorOptions{LeaseExpiration: time.Second, LeaseRenewInterval: time.Second})
+	assert.NotNil(t, p.Run(context.Background()))
+}
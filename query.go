@@ -0,0 +1,487 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Placeholder of the rewritten ORDER BY query, used for resuming queries
+	orderByFilter = "{documentdb-formattableorderbyquery-filter}"
+	// Query features that the client side pipeline is able to merge
+	queryFeatures = "Aggregate, Distinct, MultipleOrderBy, OffsetAndLimit, OrderBy, Top"
+	// API version that supports query plan requests
+	queryPlanVersion = "2018-12-31"
+)
+
+// Query execution info, returned by the gateway for partitioned queries
+type queryInfo struct {
+	DistinctType       string   `json:"distinctType"`
+	Top                *int     `json:"top"`
+	Offset             *int     `json:"offset"`
+	Limit              *int     `json:"limit"`
+	OrderBy            []string `json:"orderBy"`
+	OrderByExpressions []string `json:"orderByExpressions"`
+	GroupByExpressions []string `json:"groupByExpressions"`
+	Aggregates         []string `json:"aggregates"`
+	RewrittenQuery     string   `json:"rewrittenQuery"`
+	HasSelectValue     bool     `json:"hasSelectValue"`
+}
+
+// Effective partition key range that the query targets
+type queryRange struct {
+	Min            string `json:"min"`
+	Max            string `json:"max"`
+	IsMinInclusive bool   `json:"isMinInclusive"`
+	IsMaxInclusive bool   `json:"isMaxInclusive"`
+}
+
+type queryPlan struct {
+	Version     int          `json:"partitionedQueryExecutionInfoVersion"`
+	QueryInfo   queryInfo    `json:"queryInfo"`
+	QueryRanges []queryRange `json:"queryRanges"`
+}
+
+// Read all partition key ranges of a collection by self link
+func (c *DocumentDB) ReadPartitionKeyRanges(ctx context.Context, coll string, opts ...*RequestOptions) (ranges []PartitionKeyRange, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	qu := &Query{}
+	for {
+		var data struct {
+			Ranges []PartitionKeyRange `json:"PartitionKeyRanges,omitempty"`
+			Count  int                 `json:"_count,omitempty"`
+		}
+		if qu.Token, err = c.client.Query(ctx, coll+"pkranges/", qu, &data, headers); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, data.Ranges...)
+		if qu.Token == "" {
+			return ranges, nil
+		}
+	}
+}
+
+// Read all documents in a collection that satisfy a query, across all the
+// collection partitions. The results of each partition key range are merged
+// on the client side, including ORDER BY, TOP, OFFSET/LIMIT, DISTINCT and the
+// COUNT, SUM, MIN, MAX and AVG aggregates.
+//
+// Unlike QueryDocuments, all the result pages are fetched and decoded into docs.
+func (c *DocumentDB) QueryDocumentsCrossPartition(ctx context.Context, coll string, query *Query, docs interface{}, opts ...*RequestOptions) error {
+	if query == nil || query.Text == "" {
+		return errors.New("cross partition query requires a query text")
+	}
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	// Single partition query
+	if headers[HEADER_PARTITIONKEY] != "" {
+		items, err := c.queryRange(ctx, coll, query, headers, 0)
+		if err != nil {
+			return err
+		}
+		return decodeItems(items, docs)
+	}
+	plan, err := c.queryPlan(ctx, coll, query, headers)
+	if err != nil {
+		return err
+	}
+	info := &plan.QueryInfo
+	if len(info.GroupByExpressions) > 0 || len(info.Aggregates) > 1 {
+		return fmt.Errorf("unsupported cross partition query: %q", query.Text)
+	}
+	ranges, err := c.ReadPartitionKeyRanges(ctx, coll)
+	if err != nil {
+		return err
+	}
+	ranges = overlappingRanges(ranges, plan.QueryRanges)
+	qu := *query
+	qu.Token = ""
+	if info.RewrittenQuery != "" {
+		qu.Text = strings.Replace(info.RewrittenQuery, orderByFilter, "true", -1)
+	}
+	pages, err := c.queryRanges(ctx, coll, &qu, headers, ranges, info.rangeLimit())
+	if err != nil {
+		return err
+	}
+	items, err := info.merge(pages)
+	if err != nil {
+		return err
+	}
+	return decodeItems(items, docs)
+}
+
+// Get the query execution plan from the gateway
+func (c *DocumentDB) queryPlan(ctx context.Context, coll string, query *Query, headers map[string]string) (*queryPlan, error) {
+	h := map[string]string{
+		HEADER_QUERY_PLAN:      "True",
+		HEADER_QUERY_FEATURES:  queryFeatures,
+		HEADER_QUERY_VERSION:   "1.4",
+		HEADER_CROSS_PARTITION: "true",
+		HEADER_VER:             queryPlanVersion,
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	var plan queryPlan
+	if _, err := c.client.Query(ctx, coll+"docs/", &Query{Text: query.Text, Params: query.Params}, &plan, h); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Max number of partition key ranges queried concurrently
+const maxConcurrentRanges = 8
+
+// Run the query against each of the partition key ranges concurrently. The
+// first error cancels the other ranges.
+func (c *DocumentDB) queryRanges(ctx context.Context, coll string, query *Query, headers map[string]string, ranges []PartitionKeyRange, limit int) ([][]json.RawMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		err   error
+		pages = make([][]json.RawMessage, len(ranges))
+		sem   = make(chan struct{}, maxConcurrentRanges)
+	)
+	fail := func(e error) {
+		once.Do(func() {
+			err = e
+			cancel()
+		})
+	}
+	for i := range ranges {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// the remaining ranges are skipped, the result is partial
+		if ctx.Err() != nil {
+			fail(ctx.Err())
+			break
+		}
+		h := map[string]string{
+			HEADER_PKRANGE_ID:      ranges[i].Id,
+			HEADER_CROSS_PARTITION: "true",
+		}
+		for k, v := range headers {
+			h[k] = v
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			items, e := c.queryRange(ctx, coll, query, h, limit)
+			if e != nil {
+				fail(e)
+			}
+			pages[i] = items
+		}(i)
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Fetch all the result pages of a query, up to limit items if it's positive
+func (c *DocumentDB) queryRange(ctx context.Context, coll string, query *Query, headers map[string]string, limit int) (items []json.RawMessage, err error) {
+	qu := *query
+	for {
+		var page []json.RawMessage
+		if qu.Token, err = c.queryDocuments(ctx, coll, &qu, &page, headers); err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		if limit > 0 && len(items) >= limit {
+			return items[:limit], nil
+		}
+		if qu.Token == "" {
+			return items, nil
+		}
+	}
+}
+
+// Return the ranges that overlap with the query ranges
+func overlappingRanges(ranges []PartitionKeyRange, qranges []queryRange) []PartitionKeyRange {
+	if len(qranges) == 0 {
+		return ranges
+	}
+	var rs []PartitionKeyRange
+	for _, r := range ranges {
+		for _, q := range qranges {
+			if q.Min == q.Max && r.MinInclusive <= q.Min && q.Min < r.MaxExclusive ||
+				q.Min != q.Max && r.MinInclusive < q.Max && q.Min < r.MaxExclusive {
+				rs = append(rs, r)
+				break
+			}
+		}
+	}
+	return rs
+}
+
+// Max number of items needed from each range, or 0 if all items are needed
+func (info *queryInfo) rangeLimit() int {
+	if len(info.Aggregates) > 0 || info.distinct() {
+		return 0
+	}
+	n := 0
+	if info.Top != nil {
+		n = *info.Top
+	}
+	if info.Limit != nil {
+		l := *info.Limit
+		if info.Offset != nil {
+			l += *info.Offset
+		}
+		if n == 0 || l < n {
+			n = l
+		}
+	}
+	return n
+}
+
+func (info *queryInfo) distinct() bool {
+	return info.DistinctType != "" && info.DistinctType != "None"
+}
+
+// Merge the results of all partition key ranges
+func (info *queryInfo) merge(pages [][]json.RawMessage) (items []json.RawMessage, err error) {
+	if len(info.Aggregates) > 0 {
+		return aggregate(info.Aggregates[0], pages)
+	}
+	if len(info.OrderBy) > 0 {
+		if items, err = info.orderBy(pages); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, page := range pages {
+			items = append(items, page...)
+		}
+	}
+	if info.distinct() {
+		if items, err = distinct(items); err != nil {
+			return nil, err
+		}
+	}
+	if info.Offset != nil {
+		if *info.Offset >= len(items) {
+			items = nil
+		} else {
+			items = items[*info.Offset:]
+		}
+	}
+	if info.Limit != nil && *info.Limit < len(items) {
+		items = items[:*info.Limit]
+	}
+	if info.Top != nil && *info.Top < len(items) {
+		items = items[:*info.Top]
+	}
+	return items, nil
+}
+
+// Result item of a rewritten ORDER BY query
+type orderByItem struct {
+	Items   []map[string]interface{} `json:"orderByItems"`
+	Payload json.RawMessage          `json:"payload"`
+}
+
+// Sort the results of all ranges by their order by items
+func (info *queryInfo) orderBy(pages [][]json.RawMessage) ([]json.RawMessage, error) {
+	var all []orderByItem
+	for _, page := range pages {
+		for _, raw := range page {
+			var item orderByItem
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return nil, err
+			}
+			all = append(all, item)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		for k, order := range info.OrderBy {
+			c := compareValues(itemValue(all[i].Items, k), itemValue(all[j].Items, k))
+			if order == "Descending" {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	items := make([]json.RawMessage, 0, len(all))
+	for _, item := range all {
+		// undefined projection
+		if item.Payload != nil {
+			items = append(items, item.Payload)
+		}
+	}
+	return items, nil
+}
+
+// Return the k-th order by value, or Undefined if it's missing
+func itemValue(items []map[string]interface{}, k int) interface{} {
+	if k >= len(items) {
+		return Undefined
+	}
+	if v, ok := items[k]["item"]; ok {
+		return v
+	}
+	return Undefined
+}
+
+// Remove duplicate items, keep the first occurrence
+func distinct(items []json.RawMessage) ([]json.RawMessage, error) {
+	seen := make(map[string]bool, len(items))
+	var ret []json.RawMessage
+	for _, raw := range items {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		// marshaling sorts the map keys, and make the key canonical
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if k := string(b); !seen[k] {
+			seen[k] = true
+			ret = append(ret, raw)
+		}
+	}
+	return ret, nil
+}
+
+// Combine the partial aggregates of all ranges
+func aggregate(kind string, pages [][]json.RawMessage) ([]json.RawMessage, error) {
+	var (
+		val        interface{} = Undefined
+		sum, count float64
+	)
+	if kind == "Count" {
+		val = float64(0)
+	}
+	for _, page := range pages {
+		for _, raw := range page {
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+			// partial results are wrapped as: [{"item": value}]
+			if a, ok := v.([]interface{}); ok && len(a) > 0 {
+				v = a[0]
+			}
+			if m, ok := v.(map[string]interface{}); ok {
+				if v, ok = m["item"]; !ok {
+					continue
+				}
+			}
+			switch kind {
+			case "Count", "Sum":
+				n, ok := v.(float64)
+				if !ok {
+					continue
+				}
+				if val == Undefined {
+					val = float64(0)
+				}
+				val = val.(float64) + n
+			case "Min", "Max":
+				if c := compareValues(v, val); val == Undefined || kind == "Min" && c < 0 || kind == "Max" && c > 0 {
+					val = v
+				}
+			case "Average":
+				m, _ := v.(map[string]interface{})
+				s, _ := m["sum"].(float64)
+				n, _ := m["count"].(float64)
+				sum, count = sum+s, count+n
+			default:
+				return nil, fmt.Errorf("unsupported aggregate: %s", kind)
+			}
+		}
+	}
+	if kind == "Average" && count > 0 {
+		val = sum / count
+	}
+	if val == Undefined {
+		return nil, nil
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return []json.RawMessage{b}, nil
+}
+
+// Rank of a value type in the DocumentDB ordering
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case struct{}:
+		return 0
+	case nil:
+		return 1
+	case bool:
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// Compare two JSON values using the DocumentDB ordering:
+// undefined < null < booleans < numbers < strings < arrays < objects
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case float64:
+		if b := b.(float64); a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// Decode the result items into out
+func decodeItems(items []json.RawMessage, out interface{}) error {
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,213 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// PartitionedServer serves a query plan, two partition key ranges and
+// the result of each range
+func PartitionedServer(plan string, results map[string]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Header.Get(HEADER_QUERY_PLAN) != "":
+			fmt.Fprint(w, plan)
+		case strings.HasSuffix(r.URL.Path, "/pkranges/"):
+			fmt.Fprint(w, `{"PartitionKeyRanges": [
+				{"id": "0", "minInclusive": "", "maxExclusive": "7F"},
+				{"id": "1", "minInclusive": "7F", "maxExclusive": "FF"}
+			]}`)
+		default:
+			fmt.Fprintf(w, `{"Documents": %s}`, results[r.Header.Get(HEADER_PKRANGE_ID)])
+		}
+	}))
+}
+
+func TestQueryDocumentsCrossPartitionOrderBy(t *testing.T) {
+	assert := assert.New(t)
+	item := func(n int) string {
+		return fmt.Sprintf(`{"_rid": "r%d", "orderByItems": [{"item": %d}], "payload": {"id": "%d"}}`, n, n, n)
+	}
+	s := PartitionedServer(`{"queryInfo": {"orderBy": ["Descending"], "top": 3, "rewrittenQuery": "SELECT TOP 3 c._rid, [{\"item\": c.n}] AS orderByItems, c AS payload FROM c WHERE ({documentdb-formattableorderbyquery-filter}) ORDER BY c.n DESC"}}`, map[string]string{
+		"0": "[" + item(5) + "," + item(3) + "," + item(1) + "]",
+		"1": "[" + item(4) + "," + item(2) + "]",
+	})
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	var docs []Document
+	err := c.QueryDocumentsCrossPartition(context.Background(), "coll/", NewQuery("SELECT TOP 3 * FROM c ORDER BY c.n DESC", nil), &docs)
+	assert.Nil(err)
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, doc.Id)
+	}
+	assert.Equal([]string{"5", "4", "3"}, ids)
+}
+
+func TestQueryDocumentsCrossPartitionAggregate(t *testing.T) {
+	assert := assert.New(t)
+	s := PartitionedServer(`{"queryInfo": {"aggregates": ["Average"], "rewrittenQuery": "SELECT VALUE [{\"item\": {\"sum\": SUM(c.n), \"count\": COUNT(c.n)}}] FROM c"}}`, map[string]string{
+		"0": `[[{"item": {"sum": 6, "count": 3}}]]`,
+		"1": `[[{"item": {"sum": 4, "count": 1}}]]`,
+	})
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	var avg []float64
+	err := c.QueryDocumentsCrossPartition(context.Background(), "coll/", NewQuery("SELECT VALUE AVG(c.n) FROM c", nil), &avg)
+	assert.Nil(err)
+	assert.Equal([]float64{2.5}, avg)
+}
+
+func TestQueryDocumentsCrossPartitionDistinct(t *testing.T) {
+	assert := assert.New(t)
+	s := PartitionedServer(`{"queryInfo": {"distinctType": "Unordered", "offset": 1, "limit": 2}}`, map[string]string{
+		"0": `["a", "b", "c"]`,
+		"1": `["b", "d"]`,
+	})
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	var names []string
+	err := c.QueryDocumentsCrossPartition(context.Background(), "coll/", NewQuery("SELECT DISTINCT VALUE c.name FROM c OFFSET 1 LIMIT 2", nil), &names)
+	assert.Nil(err)
+	assert.Equal([]string{"b", "c"}, names)
+}
+
+func TestAggregate(t *testing.T) {
+	assert := assert.New(t)
+	pages := [][]json.RawMessage{
+		{json.RawMessage(`[{"item": 3}]`)},
+		{json.RawMessage(`[{"item": 7}]`), json.RawMessage(`[{}]`)},
+	}
+	for kind, expected := range map[string]string{"Count": "10", "Sum": "10", "Min": "3", "Max": "7"} {
+		items, err := aggregate(kind, pages)
+		assert.Nil(err)
+		assert.Equal(expected, string(items[0]), kind)
+	}
+	items, err := aggregate("Max", [][]json.RawMessage{{json.RawMessage(`[{}]`)}})
+	assert.Nil(err)
+	assert.Len(items, 0)
+}
+
+func TestCompareValues(t *testing.T) {
+	assert := assert.New(t)
+	ordered := []interface{}{Undefined, nil, false, true, float64(-1), float64(2), "a", "b", []interface{}{}, map[string]interface{}{}}
+	for i := 1; i < len(ordered); i++ {
+		assert.True(compareValues(ordered[i-1], ordered[i]) < 0, "%v < %v", ordered[i-1], ordered[i])
+		assert.True(compareValues(ordered[i], ordered[i-1]) > 0, "%v > %v", ordered[i], ordered[i-1])
+	}
+	assert.Equal(0, compareValues("a", "a"))
+}
+
+func TestOverlappingRanges(t *testing.T) {
+	ranges := []PartitionKeyRange{
+		{Resource: Resource{Id: "0"}, MinInclusive: "", MaxExclusive: "7F"},
+		{Resource: Resource{Id: "1"}, MinInclusive: "7F", MaxExclusive: "FF"},
+	}
+	assert.Len(t, overlappingRanges(ranges, nil), 2)
+	rs := overlappingRanges(ranges, []queryRange{{Min: "80", Max: "80", IsMinInclusive: true, IsMaxInclusive: true}})
+	assert.Len(t, rs, 1)
+	assert.Equal(t, "1", rs[0].Id)
+}
+
+func TestQueryRangesConcurrency(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	var inflight, max, calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		if inflight++; inflight > max {
+			max = inflight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		if r.Header.Get(HEADER_PKRANGE_ID) == "fail" {
+			http.Error(w, `{"code": "BadRequest", "message": "fail"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"Documents": []}`)
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	ranges := make([]PartitionKeyRange, 3*maxConcurrentRanges)
+	for i := range ranges {
+		ranges[i].Id = fmt.Sprint(i)
+	}
+	pages, err := c.queryRanges(context.Background(), "coll/", NewQuery("SELECT * FROM c", nil), nil, ranges, 0)
+	assert.Nil(err)
+	assert.Len(pages, len(ranges))
+	mu.Lock()
+	assert.True(max <= maxConcurrentRanges, "bound the concurrent ranges")
+	calls = 0
+	mu.Unlock()
+
+	// the first error cancels the remaining ranges
+	ranges[0].Id = "fail"
+	_, err = c.queryRanges(context.Background(), "coll/", NewQuery("SELECT * FROM c", nil), nil, ranges, 0)
+	assert.NotNil(err)
+	mu.Lock()
+	assert.True(calls < len(ranges), "skip the remaining ranges")
+	mu.Unlock()
+}
+
+func TestQueryRangesCanceled(t *testing.T) {
+	assert := assert.New(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Documents": [{"id": "1"}]}`)
+	}))
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the caller gives up once the first range is done
+	cancelAfterFirst := func(next Handler) Handler {
+		return func(ctx context.Context, r *Request) (*http.Response, error) {
+			resp, err := next(ctx, r)
+			if r.Header.Get(HEADER_PKRANGE_ID) == "0" {
+				cancel()
+			}
+			return resp, err
+		}
+	}
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg==", Middlewares: []Middleware{cancelAfterFirst}})
+	ranges := make([]PartitionKeyRange, 3*maxConcurrentRanges)
+	for i := range ranges {
+		ranges[i].Id = fmt.Sprint(i)
+	}
+	pages, err := c.queryRanges(ctx, "coll/", NewQuery("SELECT * FROM c", nil), nil, ranges, 0)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Nil(pages)
+
+	// canceled before the first range, whichever case of the select is taken
+	for i := 0; i < 20; i++ {
+		pages, err = c.queryRanges(ctx, "coll/", NewQuery("SELECT * FROM c", nil), nil, ranges, 0)
+		assert.True(errors.Is(err, context.Canceled))
+		assert.Nil(pages)
+	}
+}
+
+func TestReadPartitionKeyRangesOptions(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(`{"PartitionKeyRanges": [{"id": "0"}]}`)
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	ranges, err := c.ReadPartitionKeyRanges(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/", &RequestOptions{ConsistencyLevel: Eventual})
+	assert.Nil(err)
+	assert.Len(ranges, 1)
+	assert.Equal("Eventual", s.Header.Get(HEADER_CONSISTENCY))
+}
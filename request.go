@@ -1,31 +1,65 @@
 package documentdb
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	HEADER_XDATE        = "X-Ms-Date"
-	HEADER_AUTH         = "Authorization"
-	HEADER_VER          = "X-Ms-Version"
-	HEADER_CONTYPE      = "Content-Type"
-	HEADER_CONLEN       = "Content-Length"
-	HEADER_IS_QUERY     = "X-Ms-Documentdb-Isquery"
-	HEADER_UPSERT       = "X-Ms-Documentdb-Is-Upsert"
-	HEADER_CONTINUATION = "X-Ms-Continuation"
-	HEADER_IF_MATCH     = "If-Match"
-	HEADER_CHARGE       = "X-Ms-Request-Charge"
+	HEADER_XDATE              = "X-Ms-Date"
+	HEADER_AUTH               = "Authorization"
+	HEADER_VER                = "X-Ms-Version"
+	HEADER_CONTYPE            = "Content-Type"
+	HEADER_CONLEN             = "Content-Length"
+	HEADER_IS_QUERY           = "X-Ms-Documentdb-Isquery"
+	HEADER_UPSERT             = "X-Ms-Documentdb-Is-Upsert"
+	HEADER_CONTINUATION       = "X-Ms-Continuation"
+	HEADER_IF_MATCH           = "If-Match"
+	HEADER_IF_NONE_MATCH      = "If-None-Match"
+	HEADER_A_IM               = "A-Im"
+	HEADER_ETAG               = "Etag"
+	HEADER_CHARGE             = "X-Ms-Request-Charge"
+	HEADER_MAX_ITEMS          = "X-Ms-Max-Item-Count"
+	HEADER_PARTITIONKEY       = "X-Ms-Documentdb-Partitionkey"
+	HEADER_PKRANGE_ID         = "X-Ms-Documentdb-Partitionkeyrangeid"
+	HEADER_CROSS_PARTITION    = "X-Ms-Documentdb-Query-Enablecrosspartition"
+	HEADER_QUERY_PLAN         = "X-Ms-Cosmos-Is-Query-Plan-Request"
+	HEADER_QUERY_FEATURES     = "X-Ms-Cosmos-Supported-Query-Features"
+	HEADER_QUERY_VERSION      = "X-Ms-Cosmos-Query-Version"
+	HEADER_PRE_TRIGGER        = "X-Ms-Documentdb-Pre-Trigger-Include"
+	HEADER_POST_TRIGGER       = "X-Ms-Documentdb-Post-Trigger-Include"
+	HEADER_SLUG               = "Slug"
+	HEADER_CONSISTENCY        = "X-Ms-Consistency-Level"
+	HEADER_INDEXING_DIRECTIVE = "X-Ms-Indexing-Directive"
+	HEADER_SCRIPT_LOGGING     = "X-Ms-Documentdb-Script-Enable-Logging"
+	HEADER_OFFER_THROUGHPUT   = "X-Ms-Offer-Throughput"
+	HEADER_ACTIVITY_ID        = "X-Ms-Activity-Id"
+	HEADER_ITEM_COUNT         = "X-Ms-Item-Count"
+	HEADER_RESOURCE_QUOTA     = "X-Ms-Resource-Quota"
+	HEADER_RESOURCE_USAGE     = "X-Ms-Resource-Usage"
+	HEADER_RETRY_AFTER        = "X-Ms-Retry-After-Ms"
+	HEADER_SUBSTATUS          = "X-Ms-Substatus"
+	HEADER_SESSION_TOKEN      = "X-Ms-Session-Token"
+	HEADER_MAX_MEDIA_STORAGE  = "X-Ms-Max-Media-Storage-Usage-Mb"
+	HEADER_MEDIA_STORAGE      = "X-Ms-Media-Storage-Usage-Mb"
+	HEADER_USER_AGENT         = "User-Agent"
 )
 
 // Request Error
 type RequestError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// StatusCode and SubStatus are the HTTP status and the x-ms-substatus of
+	// the response
+	StatusCode    int           `json:"-"`
+	SubStatus     int           `json:"-"`
+	ActivityId    string        `json:"-"`
+	RequestCharge float64       `json:"-"`
+	RetryAfter    time.Duration `json:"-"`
 }
 
 // Implement Error function
@@ -33,35 +67,89 @@ func (e RequestError) Error() string {
 	return fmt.Sprintf("%v, %v", e.Code, e.Message)
 }
 
+// Is reports whether the error matches the given sentinel error (e.g:
+// errors.Is(err, ErrNotFound))
+func (e RequestError) Is(target error) bool {
+	status, code := 0, ""
+	switch target {
+	case ErrNotFound:
+		status, code = http.StatusNotFound, "NotFound"
+	case ErrPreconditionFailed:
+		status, code = http.StatusPreconditionFailed, "PreconditionFailed"
+	case ErrConflict:
+		status, code = http.StatusConflict, "Conflict"
+	case ErrThrottled:
+		status, code = http.StatusTooManyRequests, "TooManyRequests"
+	case ErrRequestTooLarge:
+		status, code = http.StatusRequestEntityTooLarge, "RequestEntityTooLarge"
+	default:
+		return false
+	}
+	if e.StatusCode != 0 {
+		return e.StatusCode == status
+	}
+	return e.Code == code
+}
+
+// Return the error of a failed response
+func responseError(resp *http.Response) error {
+	err := &RequestError{StatusCode: resp.StatusCode, ActivityId: resp.Header.Get(HEADER_ACTIVITY_ID)}
+	readJson(resp.Body, err)
+	err.SubStatus, _ = strconv.Atoi(resp.Header.Get(HEADER_SUBSTATUS))
+	err.RequestCharge, _ = strconv.ParseFloat(resp.Header.Get(HEADER_CHARGE), 64)
+	if ms, e := strconv.Atoi(resp.Header.Get(HEADER_RETRY_AFTER)); e == nil {
+		err.RetryAfter = time.Duration(ms) * time.Millisecond
+	}
+	return err
+}
+
 // Resource Request
 type Request struct {
-	rId, rType string
+	rId, rType, link string
 	*http.Request
 }
 
 // Return new resource request with type and id
 func ResourceRequest(link string, req *http.Request) *Request {
 	rId, rType := parse(link)
-	return &Request{rId: rId, rType: rType, Request: req}
+	return &Request{rId: rId, rType: rType, link: link, Request: req}
+}
+
+// Link returns the requested link (e.g: "dbs/b5NCAA==/colls/")
+func (req *Request) Link() string {
+	return req.link
+}
+
+// ResourceType returns the type of the requested resource (e.g: "docs")
+func (req *Request) ResourceType() string {
+	return req.rType
+}
+
+// ResourceLink returns the link of the requested resource. For feed requests,
+// it's the link of the parent resource.
+// (e.g: "/dbs/b5NCAA==/colls/" ==> "dbs/b5NCAA==")
+func (req *Request) ResourceLink() string {
+	parts := strings.Split(strings.Trim(req.link, "/"), "/")
+	if len(parts)%2 == 1 {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, "/")
 }
 
 // Add 3 default headers to *Request
 // "x-ms-date", "x-ms-version", "authorization"
 func (req *Request) DefaultHeaders(mKey string) (err error) {
-	req.Header.Add(HEADER_XDATE, time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
-	req.Header.Add(HEADER_VER, "2016-07-11")
-
-	// Auth
-	parts := []string{req.Method, req.rType, req.rId, req.Header.Get(HEADER_XDATE), req.Header.Get("Date"), ""}
-	sign, err := authorize(strings.ToLower(strings.Join(parts, "\n")), mKey)
-	if err != nil {
-		return err
-	}
+	return req.DefaultHeadersWith(MasterKey(mKey))
+}
 
-	masterToken := "master"
-	tokenVersion := "1.0"
-	req.Header.Add(HEADER_AUTH, url.QueryEscape("type="+masterToken+"&ver="+tokenVersion+"&sig="+sign))
-	return
+// DefaultHeadersWith is like DefaultHeaders, but the "authorization" header
+// is set by the given Authorizer.
+func (req *Request) DefaultHeadersWith(auth Authorizer) error {
+	req.Header.Set(HEADER_XDATE, time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	if req.Header.Get(HEADER_VER) == "" {
+		req.Header.Add(HEADER_VER, "2016-07-11")
+	}
+	return auth.Authorize(req)
 }
 
 // Add headers for query request
@@ -78,7 +166,24 @@ func (req *Request) QueryHeaders(len int, token string) {
 
 // Get path and return resource Id and Type
 // (e.g: "/dbs/b5NCAA==/" ==> "b5NCAA==", "dbs")
+// For name based links, the resource Id is the link of the resource, or of
+// its parent for feed requests.
+// (e.g: "dbs/db/colls/coll/docs/" ==> "dbs/db/colls/coll", "docs")
 func parse(id string) (rId, rType string) {
+	// the account
+	if strings.Trim(id, "/") == "" {
+		return "", ""
+	}
+	if nameBased(id) {
+		parts := strings.Split(strings.Trim(id, "/"), "/")
+		rType = parts[len(parts)-1]
+		if len(parts)%2 == 0 {
+			rType = parts[len(parts)-2]
+		} else {
+			parts = parts[:len(parts)-1]
+		}
+		return strings.Join(parts, "/"), rType
+	}
 	if strings.HasPrefix(id, "/") == false {
 		id = "/" + id
 	}
@@ -98,3 +203,15 @@ func parse(id string) (rId, rType string) {
 	}
 	return
 }
+
+// Reports whether the link addresses the resources by their ids (e.g:
+// "dbs/db/colls/coll/docs/doc"), rather than by their resource ids.
+func nameBased(link string) bool {
+	parts := strings.Split(strings.Trim(link, "/"), "/")
+	if len(parts) < 2 || parts[0] != "dbs" {
+		return false
+	}
+	// database resource ids are 4 bytes
+	b, err := base64.StdEncoding.DecodeString(strings.Replace(parts[1], "-", "/", -1))
+	return err != nil || len(b) != 4
+}
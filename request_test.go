@@ -2,8 +2,13 @@ package documentdb
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,6 +17,10 @@ func TestResourceRequest(t *testing.T) {
 	req := ResourceRequest("/dbs/b5NCAA==/", &http.Request{})
 	assert.Equal(req.rType, "dbs")
 	assert.Equal(req.rId, "b5NCAA==")
+	// the account
+	req = ResourceRequest("", &http.Request{})
+	assert.Equal(req.rType, "")
+	assert.Equal(req.rId, "")
 }
 
 func TestDefaultHeaders(t *testing.T) {
@@ -24,3 +33,70 @@ func TestDefaultHeaders(t *testing.T) {
 	assert.NotEqual(req.Header.Get(HEADER_XDATE), "")
 	assert.NotEqual(req.Header.Get(HEADER_VER), "")
 }
+
+func TestResponseError(t *testing.T) {
+	assert := assert.New(t)
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header: http.Header{
+			HEADER_SUBSTATUS:   []string{"3200"},
+			HEADER_ACTIVITY_ID: []string{"a1b2"},
+			HEADER_CHARGE:      []string{"0.38"},
+			HEADER_RETRY_AFTER: []string{"120"},
+		},
+		Body: ioutil.NopCloser(strings.NewReader(`{"code": "429", "message": "Request rate is large"}`)),
+	}
+	err := responseError(resp)
+	var e *RequestError
+	assert.True(errors.As(err, &e))
+	assert.Equal(RequestError{
+		Code:          "429",
+		Message:       "Request rate is large",
+		StatusCode:    http.StatusTooManyRequests,
+		SubStatus:     3200,
+		ActivityId:    "a1b2",
+		RequestCharge: 0.38,
+		RetryAfter:    120 * time.Millisecond,
+	}, *e)
+	assert.True(errors.Is(err, ErrThrottled))
+	assert.False(errors.Is(err, ErrNotFound))
+	assert.True(errors.Is(&RetryError{Attempts: 3, Err: err}, ErrThrottled))
+}
+
+func TestRequestErrorIs(t *testing.T) {
+	assert := assert.New(t)
+	for target, status := range map[error]int{
+		ErrNotFound:           http.StatusNotFound,
+		ErrPreconditionFailed: http.StatusPreconditionFailed,
+		ErrConflict:           http.StatusConflict,
+		ErrThrottled:          http.StatusTooManyRequests,
+		ErrRequestTooLarge:    http.StatusRequestEntityTooLarge,
+	} {
+		assert.True(errors.Is(&RequestError{StatusCode: status}, target), target.Error())
+		assert.False(errors.Is(&RequestError{StatusCode: http.StatusInternalServerError}, target), target.Error())
+	}
+	// errors without status code
+	assert.True(IsExists(&RequestError{Code: "Conflict"}))
+	assert.True(errors.Is(&RequestError{Code: "NotFound"}, ErrNotFound))
+}
+
+func TestParseNameBased(t *testing.T) {
+	assert := assert.New(t)
+	for link, want := range map[string][2]string{
+		"dbs/db":                          {"dbs/db", "dbs"},
+		"/dbs/db/colls/":                  {"dbs/db", "colls"},
+		"dbs/db/colls/coll/docs/":         {"dbs/db/colls/coll", "docs"},
+		"dbs/db/colls/coll/docs/Doc":      {"dbs/db/colls/coll/docs/Doc", "docs"},
+		"dbs/db/colls/coll/sprocs/p/":     {"dbs/db/colls/coll/sprocs/p", "sprocs"},
+		"dbs/b5NCAA==/colls/":             {"b5NCAA==", "colls"},
+		"dbs/b5NCAA==/colls/b5NCAIu9NwA=": {"b5NCAIu9NwA=", "colls"},
+	} {
+		rId, rType := parse(link)
+		assert.Equal(want[0], rId, link)
+		assert.Equal(want[1], rType, link)
+	}
+	assert.True(nameBased("dbs/db/colls/coll"))
+	assert.False(nameBased("dbs/b5NCAA==/colls/b5NCAIu9NwA="))
+	assert.False(nameBased("dbs"))
+	assert.False(nameBased("offers/b5NC"))
+}
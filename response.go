@@ -0,0 +1,125 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResponseInfo is the metadata of an operation response. Use WithResponseInfo
+// to capture it.
+type ResponseInfo struct {
+	StatusCode int
+	// RequestCharge is the cost of the operation in RUs. It's the total charge
+	// of all the requests made by the operation (e.g: retries, pages of a
+	// cross partition query).
+	RequestCharge float64
+	ActivityId    string
+	SessionToken  string
+	Etag          string
+	// RetryCount is the number of requests that were retried (e.g: throttled
+	// requests).
+	RetryCount int
+	// ItemCount is the number of items of a query or a read feed page.
+	ItemCount int
+	// ResourceQuota and ResourceUsage are the quota and the current usage of
+	// the resource (e.g: "documentsCount", "documentsSize").
+	ResourceQuota map[string]int64
+	ResourceUsage map[string]int64
+	// Header holds all the response headers.
+	Header http.Header
+}
+
+type infoKey struct{}
+
+type infoCapture struct {
+	mu     sync.Mutex
+	info   *ResponseInfo
+	parent *infoCapture
+}
+
+// WithResponseInfo returns a context that captures the metadata of the
+// responses of the operations made with it into info. When an operation
+// makes several requests, info holds the last response, and the total
+// request charge and retry count. Nested contexts capture the responses
+// into all their ResponseInfo.
+//
+// Example:
+//
+//	var info documentdb.ResponseInfo
+//	_, err := coll.CreateDocument(documentdb.WithResponseInfo(ctx, &info), &doc)
+//	// ...
+//	log.Printf("create document, charge: %v", info.RequestCharge)
+func WithResponseInfo(ctx context.Context, info *ResponseInfo) context.Context {
+	parent, _ := ctx.Value(infoKey{}).(*infoCapture)
+	return context.WithValue(ctx, infoKey{}, &infoCapture{info: info, parent: parent})
+}
+
+// Fill the ResponseInfo of the context, if any
+func captureResponseInfo(ctx context.Context, resp *http.Response, retry bool) {
+	c, _ := ctx.Value(infoKey{}).(*infoCapture)
+	for ; c != nil; c = c.parent {
+		c.capture(resp, retry)
+	}
+}
+
+// Merge the ResponseInfo of an operation that was captured separately (e.g:
+// the region of a hedged read) into the ResponseInfo of the context
+func mergeResponseInfo(ctx context.Context, info *ResponseInfo) {
+	if info.StatusCode == 0 && info.RequestCharge == 0 {
+		return
+	}
+	c, _ := ctx.Value(infoKey{}).(*infoCapture)
+	for ; c != nil; c = c.parent {
+		c.mu.Lock()
+		merged := *info
+		merged.RequestCharge += c.info.RequestCharge
+		merged.RetryCount += c.info.RetryCount
+		*c.info = merged
+		c.mu.Unlock()
+	}
+}
+
+func (c *infoCapture) capture(resp *http.Response, retry bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	charge, _ := strconv.ParseFloat(resp.Header.Get(HEADER_CHARGE), 64)
+	count, _ := strconv.Atoi(resp.Header.Get(HEADER_ITEM_COUNT))
+	retries := c.info.RetryCount
+	if retry {
+		retries++
+	}
+	*c.info = ResponseInfo{
+		StatusCode:    resp.StatusCode,
+		RequestCharge: c.info.RequestCharge + charge,
+		RetryCount:    retries,
+		ActivityId:    resp.Header.Get(HEADER_ACTIVITY_ID),
+		SessionToken:  resp.Header.Get(HEADER_SESSION_TOKEN),
+		Etag:          resp.Header.Get(HEADER_ETAG),
+		ItemCount:     count,
+		ResourceQuota: parseResourceUsage(resp.Header.Get(HEADER_RESOURCE_QUOTA)),
+		ResourceUsage: parseResourceUsage(resp.Header.Get(HEADER_RESOURCE_USAGE)),
+		Header:        resp.Header,
+	}
+}
+
+// Parse quota or usage header
+// (e.g: "documentsSize=0;documentsCount=3;" ==> {"documentsSize": 0, "documentsCount": 3})
+func parseResourceUsage(s string) map[string]int64 {
+	if s == "" {
+		return nil
+	}
+	m := make(map[string]int64)
+	for _, kv := range strings.Split(s, ";") {
+		i := strings.Index(kv, "=")
+		if i == -1 {
+			continue
+		}
+		if n, err := strconv.ParseInt(kv[i+1:], 10, 64); err == nil {
+			m[kv[:i]] = n
+		}
+	}
+	return m
+}
@@ -0,0 +1,55 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResourceUsage(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(parseResourceUsage(""))
+	assert.Equal(map[string]int64{"documentsSize": 0, "documentsCount": 3}, parseResourceUsage("documentsSize=0;documentsCount=3;functions=x;"))
+}
+
+func TestResponseInfo(t *testing.T) {
+	assert := assert.New(t)
+	status := []int{http.StatusTooManyRequests, http.StatusOK}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CHARGE, "1.5")
+		w.Header().Set(HEADER_ACTIVITY_ID, "a1b2")
+		w.Header().Set(HEADER_SESSION_TOKEN, "0:-1#12")
+		w.Header().Set(HEADER_ETAG, `"etag"`)
+		w.Header().Set(HEADER_ITEM_COUNT, "2")
+		w.Header().Set(HEADER_RESOURCE_QUOTA, "documentsSize=10240;")
+		w.Header().Set(HEADER_RESOURCE_USAGE, "documentsSize=12;")
+		w.WriteHeader(status[0])
+		status = status[1:]
+		w.Write([]byte(`{"Documents": [{"id": "1"}, {"id": "2"}]}`))
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg==", MaxRetries: 1})
+
+	var (
+		info ResponseInfo
+		docs []Document
+	)
+	var outer ResponseInfo
+	ctx := WithResponseInfo(WithResponseInfo(context.Background(), &outer), &info)
+	_, err := c.QueryDocuments(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/", nil, &docs)
+	assert.Nil(err)
+	assert.Len(docs, 2)
+	assert.Equal(http.StatusOK, info.StatusCode)
+	assert.Equal(3.0, info.RequestCharge, "should sum the charge of the retries")
+	assert.Equal(1, info.RetryCount)
+	assert.Equal("a1b2", info.ActivityId)
+	assert.Equal("0:-1#12", info.SessionToken)
+	assert.Equal(`"etag"`, info.Etag)
+	assert.Equal(2, info.ItemCount)
+	assert.Equal(map[string]int64{"documentsSize": 10240}, info.ResourceQuota)
+	assert.Equal(map[string]int64{"documentsSize": 12}, info.ResourceUsage)
+	assert.Equal(info, outer)
+}
@@ -0,0 +1,120 @@
+package documentdb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Attempt describes a failed attempt of a request.
+type Attempt struct {
+	Request *Request
+	// Response of the attempt, or nil if it failed with Err (e.g: network
+	// error).
+	Response *http.Response
+	Err      error
+	// Count is the number of attempts made so far, including this one.
+	Count int
+	// Waited is the total time waited between the attempts so far.
+	Waited time.Duration
+}
+
+// RetryPolicy decides if a failed request should be retried. It's called
+// after each failed attempt, and returns the delay before the next attempt.
+// Requests with a body that can't be rewound are never retried.
+type RetryPolicy interface {
+	Retry(a Attempt) (delay time.Duration, retry bool)
+}
+
+// DefaultRetryPolicy retries throttled (429) and unavailable (503) requests,
+// and idempotent requests (i.e: reads, queries, replaces and deletes) that
+// failed with a network error, a timeout (408) or a retry-with (449) status.
+//
+// The delay is the x-ms-retry-after-ms header of the response when it's
+// given, or an exponential backoff.
+type DefaultRetryPolicy struct {
+	// MaxRetries is the max number of retries of a request
+	MaxRetries int
+	// MaxWait is the max cumulative wait of a request, defaults to 30 seconds.
+	// A delay that exceeds it is shortened to the rest of the wait.
+	MaxWait time.Duration
+}
+
+func (p *DefaultRetryPolicy) Retry(a Attempt) (time.Duration, bool) {
+	if a.Count > p.MaxRetries {
+		return 0, false
+	}
+	if !p.retriable(a) {
+		return 0, false
+	}
+	delay := backoffDelay(a.Count - 1)
+	if a.Response != nil {
+		if ms, err := strconv.Atoi(a.Response.Header.Get(HEADER_RETRY_AFTER)); err == nil {
+			delay = time.Duration(ms) * time.Millisecond
+		}
+	}
+	max := p.MaxWait
+	if max == 0 {
+		max = 30 * time.Second
+	}
+	// the last retry waits for the rest of the max wait
+	if a.Waited+delay > max {
+		delay = max - a.Waited
+	}
+	if delay <= 0 {
+		return 0, false
+	}
+	return delay, true
+}
+
+func (p *DefaultRetryPolicy) retriable(a Attempt) bool {
+	if a.Err != nil {
+		return !errors.Is(a.Err, ErrCircuitOpen) && idempotent(a.Request)
+	}
+	switch a.Response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusRequestTimeout, StatusRetryWith:
+		return idempotent(a.Request)
+	}
+	return false
+}
+
+// StatusRetryWith is returned when a request conflicts with a concurrent
+// write, and should be retried.
+const StatusRetryWith = 449
+
+// Reports whether the request can be sent more than once
+func idempotent(r *Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	case "POST":
+		return r.Header.Get(HEADER_IS_QUERY) != "" || r.Header.Get(HEADER_QUERY_PLAN) != ""
+	}
+	return false
+}
+
+// RetryError is the error of a request that failed after several attempts.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap the error of a request with its attempts
+func retryError(err error, attempts int) error {
+	if attempts < 2 {
+		return err
+	}
+	return &RetryError{Attempts: attempts, Err: err}
+}
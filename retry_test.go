@@ -0,0 +1,148 @@
+package documentdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	assert := assert.New(t)
+	p := &DefaultRetryPolicy{MaxRetries: 2, MaxWait: time.Second}
+	newAttempt := func(method string, status int, retryAfter string) Attempt {
+		r, _ := http.NewRequest(method, "link", &bytes.Buffer{})
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set(HEADER_RETRY_AFTER, retryAfter)
+		}
+		return Attempt{Request: ResourceRequest("dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", r), Response: resp, Count: 1}
+	}
+
+	delay, ok := p.Retry(newAttempt("POST", http.StatusTooManyRequests, "120"))
+	assert.True(ok)
+	assert.Equal(120*time.Millisecond, delay, "should respect the retry-after header")
+
+	a := newAttempt("POST", http.StatusTooManyRequests, "120")
+	a.Count = 3
+	_, ok = p.Retry(a)
+	assert.False(ok, "should stop after max retries")
+
+	a = newAttempt("POST", http.StatusTooManyRequests, "500")
+	a.Waited = 600 * time.Millisecond
+	delay, ok = p.Retry(a)
+	assert.True(ok)
+	assert.Equal(400*time.Millisecond, delay, "should cap the delay to the rest of the wait")
+	a.Waited = time.Second
+	_, ok = p.Retry(a)
+	assert.False(ok, "should cap the cumulative wait")
+
+	_, ok = p.Retry(newAttempt("POST", http.StatusRequestTimeout, ""))
+	assert.False(ok, "should not retry non idempotent requests")
+	_, ok = p.Retry(newAttempt("GET", http.StatusRequestTimeout, ""))
+	assert.True(ok)
+	_, ok = p.Retry(newAttempt("PUT", StatusRetryWith, ""))
+	assert.True(ok)
+	_, ok = p.Retry(newAttempt("GET", http.StatusNotFound, ""))
+	assert.False(ok)
+
+	a = newAttempt("POST", 0, "")
+	a.Request.Header.Set(HEADER_IS_QUERY, "true")
+	a.Response, a.Err = nil, errors.New("connection reset")
+	_, ok = p.Retry(a)
+	assert.True(ok, "should retry queries on network errors")
+	a.Request.Header.Del(HEADER_IS_QUERY)
+	_, ok = p.Retry(a)
+	assert.False(ok)
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// drop the connection
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", RetryPolicy: &DefaultRetryPolicy{MaxRetries: 1}}}
+
+	var doc Document
+	_, err := client.Query(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", nil, &doc, nil)
+	assert.Nil(err)
+	assert.Equal("1", doc.Id)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	// creates are not retried
+	atomic.StoreInt32(&calls, 0)
+	err = client.Create(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", doc, &doc, nil)
+	assert.NotNil(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryError(t *testing.T) {
+	assert := assert.New(t)
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(HEADER_RETRY_AFTER, "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code": "TooManyRequests", "message": "Request rate is large"}`))
+	}))
+	defer s.Close()
+	client := &Client{Url: s.URL, Config: Config{MasterKey: "YXJpZWwNCg==", MaxRetries: 2}}
+
+	var doc Document
+	err := client.Create(context.Background(), "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", doc, &doc, nil)
+	assert.Equal(3, calls)
+	var re *RetryError
+	assert.True(errors.As(err, &re))
+	assert.Equal(3, re.Attempts)
+	var reqErr *RequestError
+	assert.True(errors.As(err, &reqErr))
+	assert.Equal("TooManyRequests", reqErr.Code)
+}
+
+type countsPolicy []int
+
+func (p *countsPolicy) Retry(a Attempt) (time.Duration, bool) {
+	*p = append(*p, a.Count)
+	return time.Millisecond, len(*p) < 2
+}
+
+func TestRetryKeyRotation(t *testing.T) {
+	assert := assert.New(t)
+	status := []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status[0])
+		status = status[1:]
+		w.Write([]byte(`{"code": "Error", "message": "error"}`))
+	}))
+	defer s.Close()
+	auth, err := NewRotatingKey(Keys{Primary: "YXJpZWwNCg==", Secondary: "YTht"})
+	assert.Nil(err)
+	var counts countsPolicy
+	client := &Client{Url: s.URL, Config: Config{Authorizer: auth, RetryPolicy: &counts}}
+
+	var doc Document
+	var info ResponseInfo
+	ctx := WithResponseInfo(context.Background(), &info)
+	err = client.Create(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/", doc, &doc, nil)
+	assert.Empty(status)
+	// the rotation of the key is not a retry
+	assert.Equal(countsPolicy{1, 2}, counts)
+	var re *RetryError
+	assert.True(errors.As(err, &re))
+	assert.Equal(2, re.Attempts)
+	assert.Equal(1, info.RetryCount)
+}
@@ -0,0 +1,156 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Session tokens of the collections. A collection session token is a list of
+// partition key range tokens (e.g: "0:-1#1204,1:-1#873"), where the last
+// number of each token is the LSN of the range.
+type sessionTokens struct {
+	mu sync.Mutex
+	m  map[string]map[string]string // collection link => range id => token
+}
+
+// Return the session token of a collection
+func (s *sessionTokens) get(coll string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranges := s.m[coll]
+	tokens := make([]string, 0, len(ranges))
+	for id, tok := range ranges {
+		tokens = append(tokens, id+":"+tok)
+	}
+	sort.Strings(tokens)
+	return strings.Join(tokens, ",")
+}
+
+// Merge a session token into the collection session, keeping the latest
+// token of each range
+func (s *sessionTokens) merge(coll, token string) {
+	if coll == "" || token == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]map[string]string)
+	}
+	ranges := s.m[coll]
+	if ranges == nil {
+		ranges = make(map[string]string)
+		s.m[coll] = ranges
+	}
+	for _, t := range strings.Split(token, ",") {
+		i := strings.Index(t, ":")
+		if i == -1 {
+			continue
+		}
+		id, tok := strings.TrimSpace(t[:i]), t[i+1:]
+		if cur, ok := ranges[id]; !ok || lsn(tok) >= lsn(cur) {
+			ranges[id] = tok
+		}
+	}
+}
+
+// Set the session token of reads, unless it's given explicitly or the
+// consistency is not Session.
+func (s *sessionTokens) apply(ctx context.Context, r *Request) {
+	if r.Header.Get(HEADER_SESSION_TOKEN) != "" {
+		return
+	}
+	if l := r.Header.Get(HEADER_CONSISTENCY); l != "" && l != string(Session) {
+		return
+	}
+	if r.Method != "GET" && r.Header.Get(HEADER_IS_QUERY) == "" {
+		return
+	}
+	if tok := s.get(collectionKey(ctx, r)); tok != "" {
+		r.Header.Set(HEADER_SESSION_TOKEN, tok)
+	}
+}
+
+// Capture the session token of a response
+func (s *sessionTokens) capture(ctx context.Context, r *Request, resp *http.Response) {
+	if tok := resp.Header.Get(HEADER_SESSION_TOKEN); tok != "" {
+		s.merge(collectionKey(ctx, r), tok)
+	}
+}
+
+// Return the global LSN of a range session token, which is the second part of
+// both the V1 and the V2 (multi-region) tokens.
+// (e.g: "-1#1204" ==> 1204, "1#1204#3=1200#4=1100" ==> 1204)
+func lsn(tok string) int64 {
+	parts := strings.Split(tok, "#")
+	if len(parts) < 2 {
+		return 0
+	}
+	n, _ := strconv.ParseInt(parts[1], 10, 64)
+	return n
+}
+
+// Return the collection link of a resource link, or an empty string if it's
+// not a collection resource.
+// (e.g: "/dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/" ==> "dbs/b5NCAA==/colls/b5NCAIu9NwA=")
+func collectionLink(link string) string {
+	parts := strings.Split(strings.Trim(link, "/"), "/")
+	if len(parts) < 4 || parts[2] != "colls" {
+		return ""
+	}
+	return strings.Join(parts[:4], "/")
+}
+
+// selfKey is the self link of the collection of the requests made with Col.
+type selfKey struct{}
+
+// Return the collection of a request, which keys its session and its circuit
+// (see CircuitBreaker). The requests made with Col are keyed by the self link
+// of the collection, whether they use its name based or its self link. Other
+// requests are keyed by their link as is, so a collection that is requested
+// directly by both its name based and its self link has two keys.
+func collectionKey(ctx context.Context, r *Request) string {
+	if l, ok := ctx.Value(selfKey{}).(string); ok && collectionLink(r.link) != "" {
+		return l
+	}
+	return collectionLink(r.link)
+}
+
+// Implemented by clients that keep the collections session
+type sessionClient interface {
+	SessionToken(coll string) string
+	SetSessionToken(coll, token string)
+}
+
+// SessionToken returns the session token of a collection by its self link.
+// It can be passed to another process, that continues the session with
+// SetSessionToken or RequestOptions.SessionToken, to read its own writes.
+// The requests that were made directly with the name based link of the
+// collection (i.e: not with Col) have their own session, by that link.
+func (c *DocumentDB) SessionToken(coll string) string {
+	if s, ok := c.client.(sessionClient); ok {
+		return s.SessionToken(coll)
+	}
+	return ""
+}
+
+// SetSessionToken merges the given token into the session of a collection.
+func (c *DocumentDB) SetSessionToken(coll, token string) {
+	if s, ok := c.client.(sessionClient); ok {
+		s.SetSessionToken(coll, token)
+	}
+}
+
+// SessionToken returns the session token of the collection
+func (c *Col) SessionToken() string {
+	return c.db.c.SessionToken(c.Self)
+}
+
+// SetSessionToken merges the given token into the session of the collection
+func (c *Col) SetSessionToken(token string) {
+	c.db.c.SetSessionToken(c.Self, token)
+}
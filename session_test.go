@@ -0,0 +1,100 @@
+package documentdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionLink(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("dbs/b5NCAA==/colls/b5NCAIu9NwA=", collectionLink("/dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/"))
+	assert.Equal("dbs/b5NCAA==/colls/b5NCAIu9NwA=", collectionLink("dbs/b5NCAA==/colls/b5NCAIu9NwA=/"))
+	assert.Equal("", collectionLink("dbs/b5NCAA==/colls/"))
+	assert.Equal("", collectionLink("dbs/b5NCAA==/users/b5NCAIu9NwA=/permissions/"))
+}
+
+func TestSessionTokensMerge(t *testing.T) {
+	assert := assert.New(t)
+	var s sessionTokens
+	s.merge("coll", "0:-1#12")
+	s.merge("coll", "1:-1#7")
+	assert.Equal("0:-1#12,1:-1#7", s.get("coll"))
+	// older tokens are ignored
+	s.merge("coll", "0:-1#10,1:-1#9")
+	assert.Equal("0:-1#12,1:-1#9", s.get("coll"))
+	s.merge("", "0:-1#20")
+	assert.Equal("", s.get(""))
+	assert.Equal("", s.get("other"))
+
+	// V2 tokens are compared by their global LSN
+	s.merge("v2", "0:1#1204#3=1200#4=1100")
+	s.merge("v2", "0:1#1190#3=1210#4=1190")
+	assert.Equal("0:1#1204#3=1200#4=1100", s.get("v2"))
+	s.merge("v2", "0:2#1205#3=1201")
+	assert.Equal("0:2#1205#3=1201", s.get("v2"))
+}
+
+func TestLSN(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int64(1204), lsn("-1#1204"))
+	assert.Equal(int64(1204), lsn("1#1204#3=1200"))
+	assert.Equal(int64(1204), lsn("1#1204#3=1200#4=1100"))
+	assert.Equal(int64(0), lsn("1204"))
+	assert.Equal(int64(0), lsn(""))
+}
+
+func TestSessionTokenReplay(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		lsn     = 0
+		headers []http.Header
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		lsn++
+		w.Header().Set(HEADER_SESSION_TOKEN, "0:-1#"+strconv.Itoa(lsn))
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer s.Close()
+	c := New(s.URL, Config{MasterKey: "YXJpZWwNCg=="})
+	coll := &Col{db: &DB{c: c}, Collection: Collection{Resource: Resource{Self: "dbs/b5NCAA==/colls/b5NCAIu9NwA=/"}}}
+	ctx := context.Background()
+
+	_, err := coll.CreateDocument(ctx, &Document{})
+	assert.Nil(err)
+	assert.Equal("", headers[0].Get(HEADER_SESSION_TOKEN), "writes don't send the session token")
+	assert.Equal("0:-1#1", coll.SessionToken())
+
+	var doc Document
+	assert.Nil(coll.ReadDocumentByLink(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc))
+	assert.Equal("0:-1#1", headers[1].Get(HEADER_SESSION_TOKEN))
+	assert.Equal("0:-1#2", coll.SessionToken())
+
+	// session token from another service
+	coll.SetSessionToken("0:-1#5")
+	assert.Nil(coll.ReadDocumentByLink(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc))
+	assert.Equal("0:-1#5", headers[2].Get(HEADER_SESSION_TOKEN))
+
+	// consistency override
+	assert.Nil(coll.ReadDocumentByLink(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc, &RequestOptions{ConsistencyLevel: Eventual}))
+	assert.Equal("", headers[3].Get(HEADER_SESSION_TOKEN))
+	assert.Equal("Eventual", headers[3].Get(HEADER_CONSISTENCY))
+
+	assert.Nil(coll.ReadDocumentByLink(ctx, "dbs/b5NCAA==/colls/b5NCAIu9NwA=/docs/b5NCAIu9NwABAAAAAAAAAA==/", &doc, &RequestOptions{SessionToken: "0:-1#3"}))
+	assert.Equal("0:-1#3", headers[4].Get(HEADER_SESSION_TOKEN))
+}
+
+func TestCollectionKey(t *testing.T) {
+	assert := assert.New(t)
+	r := ResourceRequest("dbs/db/colls/coll/docs/doc", &http.Request{})
+	assert.Equal("dbs/db/colls/coll", collectionKey(context.Background(), r))
+	// requests made with Col share the session of its self link
+	ctx := context.WithValue(context.Background(), selfKey{}, "dbs/b5NCAA==/colls/b5NCAIu9NwA=")
+	assert.Equal("dbs/b5NCAA==/colls/b5NCAIu9NwA=", collectionKey(ctx, r))
+	assert.Equal("", collectionKey(ctx, ResourceRequest("dbs/db", &http.Request{})))
+}
@@ -0,0 +1,93 @@
+package documentdb
+
+import (
+	"context"
+)
+
+// CreateTrigger create a trigger of the given type and operation. Triggers
+// run only when they are included explicitly in the request options of a
+// document write (see RequestOptions.PreTriggers and PostTriggers).
+func (c *Col) CreateTrigger(ctx context.Context, id string, typ TriggerType, op TriggerOperation, fnc string, opts ...*RequestOptions) (*Trigger, error) {
+	t := &Trigger{Body: fnc, Type: typ, Operation: op}
+	t.Id = id
+	if err := c.db.c.CreateTrigger(c.ctx(ctx), c.Self, t, opts...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (c *Col) Trigger(ctx context.Context, id string, opts ...*RequestOptions) (*Trigger, error) {
+	trigger, err := c.db.c.ReadTrigger(c.ctx(ctx), c.link()+"triggers/"+id, opts...)
+	if err != nil {
+		return nil, err
+	} else if trigger == nil {
+		return nil, ErrNotFound
+	}
+	return trigger, nil
+}
+
+// Read trigger by self link
+func (c *DocumentDB) ReadTrigger(ctx context.Context, link string, opts ...*RequestOptions) (trigger *Trigger, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &trigger, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read all triggers by collection self link
+func (c *DocumentDB) ReadTriggers(ctx context.Context, coll string, opts ...*RequestOptions) (triggers []Trigger, err error) {
+	return c.QueryTriggers(ctx, coll, nil, opts...)
+}
+
+// Read all collection `triggers` that satisfy a query
+func (c *DocumentDB) QueryTriggers(ctx context.Context, coll string, query *Query, opts ...*RequestOptions) (triggers []Trigger, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Triggers []Trigger `json:"Triggers,omitempty"`
+		Count    int       `json:"_count,omitempty"`
+	}
+	_, err = c.client.Query(ctx, coll+"triggers/", query, &data, headers)
+	if triggers = data.Triggers; err != nil {
+		triggers = nil
+	}
+	return
+}
+
+// Create trigger
+func (c *DocumentDB) CreateTrigger(ctx context.Context, coll string, trigger *Trigger, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Create(ctx, coll+"triggers/", trigger, trigger, headers)
+}
+
+// Replace trigger
+func (c *DocumentDB) ReplaceTrigger(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (trigger *Trigger, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &trigger, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Delete trigger
+func (c *DocumentDB) DeleteTrigger(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
+}
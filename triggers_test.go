@@ -0,0 +1,61 @@
+package documentdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadTrigger(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "self_link", (*Query)(nil)).Return("", nil)
+	c.ReadTrigger(context.Background(), "self_link")
+	client.AssertCalled(t, "Query", "self_link", (*Query)(nil))
+}
+
+func TestReadTriggers(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "colllink/triggers/", (*Query)(nil)).Return("", nil)
+	c.ReadTriggers(context.Background(), "colllink/")
+	client.AssertCalled(t, "Query", "colllink/triggers/", (*Query)(nil))
+}
+
+func TestColTrigger(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "dbs/db/colls/coll/triggers/stamp", (*Query)(nil)).Return("", nil)
+	col := &Col{db: &DB{c: c, Database: Database{Resource: Resource{Id: "db"}}}, Collection: Collection{Resource: Resource{Id: "coll", Self: "colllink/"}}}
+	_, err := col.Trigger(context.Background(), "stamp")
+	if err != ErrNotFound {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+	client.AssertCalled(t, "Query", "dbs/db/colls/coll/triggers/stamp", (*Query)(nil))
+}
+
+func TestCreateTrigger(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	trigger := &Trigger{Body: "function() {}", Type: TriggerPre, Operation: TriggerCreate}
+	trigger.Id = "stamp"
+	client.On("Create", "colllink/triggers/", trigger).Return(nil)
+	col := &Col{db: &DB{c: c}, Collection: Collection{Resource: Resource{Self: "colllink/"}}}
+	col.CreateTrigger(context.Background(), "stamp", TriggerPre, TriggerCreate, "function() {}")
+	client.AssertCalled(t, "Create", "colllink/triggers/", trigger)
+}
+
+func TestReplaceTrigger(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Replace", "trigger_link", "{}").Return(nil)
+	c.ReplaceTrigger(context.Background(), "trigger_link", "{}")
+	client.AssertCalled(t, "Replace", "trigger_link", "{}")
+}
+
+func TestDeleteTrigger(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Delete", "trigger_link").Return(nil)
+	c.DeleteTrigger(context.Background(), "trigger_link")
+	client.AssertCalled(t, "Delete", "trigger_link")
+}
@@ -0,0 +1,159 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+)
+
+// Item is a document of a TypedCol, with its system properties (e.g: Etag,
+// Self and Ts). The document type doesn't need to embed Resource.
+type Item[T any] struct {
+	Value T
+	Resource
+}
+
+// Decode both the document and its system properties
+func (i *Item[T]) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &i.Value); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &i.Resource)
+}
+
+// TypedCol is a collection of documents of type T.
+//
+// Example:
+//
+//	users := documentdb.Typed[User](coll)
+//	user, err := users.Get(ctx, "foo")
+//	for user, err := range users.Query(ctx, documentdb.NewQuery("SELECT * FROM root r", nil)) {
+//		// ...
+//	}
+type TypedCol[T any] struct {
+	col *Col
+}
+
+// Typed returns the typed view of a collection
+func Typed[T any](c *Col) *TypedCol[T] {
+	return &TypedCol[T]{col: c}
+}
+
+// Col returns the underlying collection
+func (c *TypedCol[T]) Col() *Col {
+	return c.col
+}
+
+// Get read document by id. The partition key of the document must be given
+// on partitioned collections.
+func (c *TypedCol[T]) Get(ctx context.Context, id string, opts ...*RequestOptions) (T, error) {
+	item, err := c.GetItem(ctx, id, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return item.Value, nil
+}
+
+// GetItem read document by id, with its system properties
+func (c *TypedCol[T]) GetItem(ctx context.Context, id string, opts ...*RequestOptions) (*Item[T], error) {
+	var item Item[T]
+	if err := c.col.db.c.ReadDocument(c.col.ctx(ctx), c.link(id), &item, opts...); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Query returns an iterator over the documents that satisfy the query, or
+// over all the collection documents if the query is nil. The iteration stops
+// after the first error.
+func (c *TypedCol[T]) Query(ctx context.Context, qu *Query, opts ...*RequestOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range c.QueryItems(ctx, qu, opts...) {
+			var v T
+			if item != nil {
+				v = item.Value
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// QueryItems is like Query, with the system properties of the documents
+func (c *TypedCol[T]) QueryItems(ctx context.Context, qu *Query, opts ...*RequestOptions) iter.Seq2[*Item[T], error] {
+	return func(yield func(*Item[T], error) bool) {
+		it := c.col.IterateDocuments(qu, IteratorOptions{}, opts...)
+		defer it.Close()
+		for it.Next(ctx) {
+			var item Item[T]
+			if err := it.Decode(&item); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(&item, nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Create document, and return it as stored
+func (c *TypedCol[T]) Create(ctx context.Context, doc T, opts ...*RequestOptions) (*Item[T], error) {
+	setId(&doc)
+	return c.create(ctx, doc, nil, opts)
+}
+
+// Upsert document, and return it as stored
+func (c *TypedCol[T]) Upsert(ctx context.Context, doc T, opts ...*RequestOptions) (*Item[T], error) {
+	setId(&doc)
+	return c.create(ctx, doc, map[string]string{HEADER_UPSERT: "true"}, opts)
+}
+
+func (c *TypedCol[T]) create(ctx context.Context, doc T, headers map[string]string, opts []*RequestOptions) (*Item[T], error) {
+	o, err := c.col.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if headers, err = o.headers(headers); err != nil {
+		return nil, err
+	}
+	var item Item[T]
+	if err := c.col.db.c.client.Create(c.col.ctx(ctx), c.col.Self+"docs/", doc, &item, headers); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Replace document by id, and return it as stored. Use IfMatch to replace
+// it only if it wasn't changed since it was read.
+func (c *TypedCol[T]) Replace(ctx context.Context, id string, doc T, opts ...*RequestOptions) (*Item[T], error) {
+	o, err := c.col.docOptions(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := o.headers(nil)
+	if err != nil {
+		return nil, err
+	}
+	var item Item[T]
+	if err := c.col.db.c.client.Replace(c.col.ctx(ctx), c.link(id), doc, &item, headers); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Delete document by id. The partition key of the document must be given on
+// partitioned collections.
+func (c *TypedCol[T]) Delete(ctx context.Context, id string, opts ...*RequestOptions) error {
+	return c.col.DeleteDocument(ctx, id, "", opts...)
+}
+
+// Return the name based link of a document
+func (c *TypedCol[T]) link(id string) string {
+	return c.col.link() + "docs/" + id
+}
@@ -0,0 +1,106 @@
+package documentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedCol(t *testing.T) {
+	assert := assert.New(t)
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		b, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/missing"):
+			http.Error(w, `{"code": "NotFound", "message": "Resource Not Found"}`, http.StatusNotFound)
+		case r.Method == "GET":
+			fmt.Fprint(w, `{"id": "1", "name": "a8m", "_self": "dbs/db/colls/coll/docs/1/", "_etag": "etag1", "_ts": 1}`)
+		case r.Header.Get(HEADER_IS_QUERY) != "":
+			fmt.Fprint(w, `{"Documents": [
+				{"id": "1", "name": "a8m", "_self": "dbs/db/colls/coll/docs/1/", "_etag": "etag1", "_ts": 1},
+				{"id": "2", "name": "ariel", "_self": "dbs/db/colls/coll/docs/2/", "_etag": "etag2", "_ts": 2}
+			]}`)
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			var u typedUser
+			json.Unmarshal(b, &u)
+			fmt.Fprintf(w, `{"id": %q, "name": %q, "_self": "dbs/db/colls/coll/docs/%s/", "_etag": "etag", "_ts": 3}`, u.Id, u.Name, u.Id)
+		}
+	}))
+	defer s.Close()
+	db := &DB{c: New(s.URL, Config{MasterKey: "YXJpZWwNCg=="}), Database: Database{Resource: Resource{Id: "db", Self: "dbs/db/"}}}
+	users := Typed[typedUser](&Col{db: db, Collection: Collection{Resource: Resource{Id: "coll", Self: "dbs/db/colls/coll/"}}})
+	ctx := context.Background()
+
+	u, err := users.Get(ctx, "1")
+	assert.Nil(err)
+	assert.Equal(typedUser{Id: "1", Name: "a8m"}, u)
+	_, err = users.Get(ctx, "missing")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	item, err := users.GetItem(ctx, "1")
+	assert.Nil(err)
+	assert.Equal("etag1", item.Etag)
+	assert.Equal("dbs/db/colls/coll/docs/1/", item.Self)
+	assert.Equal(1, item.Ts)
+	assert.Equal([]string{"GET /dbs/db/colls/coll/docs/1", "GET /dbs/db/colls/coll/docs/missing", "GET /dbs/db/colls/coll/docs/1"}, requests)
+
+	var names []string
+	for u, err := range users.Query(ctx, NewQuery("SELECT * FROM root r", nil)) {
+		assert.Nil(err)
+		names = append(names, u.Name)
+	}
+	assert.Equal([]string{"a8m", "ariel"}, names)
+	for item, err := range users.QueryItems(ctx, nil) {
+		assert.Nil(err)
+		assert.Equal("etag1", item.Etag)
+		break
+	}
+
+	item, err = users.Create(ctx, typedUser{Id: "3", Name: "foo"})
+	assert.Nil(err)
+	assert.Equal(typedUser{Id: "3", Name: "foo"}, item.Value)
+	assert.Equal("etag", item.Etag)
+
+	item, err = users.Upsert(ctx, typedUser{Name: "bar"})
+	assert.Nil(err)
+	assert.NotEqual("", item.Value.Id, "generate the id")
+
+	requests = nil
+	item, err = users.Replace(ctx, "1", typedUser{Id: "1", Name: "baz"}, &RequestOptions{IfMatch: "etag1"})
+	assert.Nil(err)
+	assert.Equal("baz", item.Value.Name)
+	assert.Nil(users.Delete(ctx, "1"))
+	// documents are addressed by id, without a lookup query
+	assert.Equal([]string{"PUT /dbs/db/colls/coll/docs/1", "DELETE /dbs/db/colls/coll/docs/1"}, requests)
+}
+
+func TestTypedColQueryError(t *testing.T) {
+	assert := assert.New(t)
+	s := ServerFactory(500)
+	defer s.Close()
+	db := &DB{c: New(s.URL, Config{MasterKey: "YXJpZWwNCg=="}), Database: Database{Resource: Resource{Self: "dbs/db/"}}}
+	users := Typed[typedUser](&Col{db: db, Collection: Collection{Resource: Resource{Self: "dbs/db/colls/coll/"}}})
+	n := 0
+	for _, err := range users.Query(context.Background(), nil) {
+		assert.NotNil(err)
+		n++
+	}
+	assert.Equal(1, n)
+}
@@ -0,0 +1,196 @@
+package documentdb
+
+import (
+	"context"
+)
+
+func (db *DB) CreateUser(ctx context.Context, id string, opts ...*RequestOptions) (*Usr, error) {
+	u, err := db.c.CreateUser(ctx, db.Self, map[string]string{"id": id}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Usr{db: db, User: *u}, nil
+}
+
+func (db *DB) User(ctx context.Context, id string, opts ...*RequestOptions) (*Usr, error) {
+	user, err := db.c.ReadUser(ctx, "dbs/"+db.Id+"/users/"+id, opts...)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, ErrNotFound
+	}
+	return &Usr{db: db, User: *user}, nil
+}
+
+type Usr struct {
+	db *DB
+	User
+}
+
+func (u *Usr) Delete(ctx context.Context, opts ...*RequestOptions) error {
+	return u.db.c.DeleteUser(ctx, u.Self, opts...)
+}
+
+// CreatePermission grant the user access to a resource (e.g: collection self
+// link). If pk is not nil, the access is limited to the documents of the
+// given partition key.
+func (u *Usr) CreatePermission(ctx context.Context, id string, mode PermissionMode, resource string, pk interface{}, opts ...*RequestOptions) (*Permission, error) {
+	p := &Permission{PermissionMode: mode, ResourceLink: resource}
+	p.Id = id
+	if pk != nil {
+		p.ResourcePartitionKey = []interface{}{pk}
+	}
+	return u.db.c.CreatePermission(ctx, u.Self, p, opts...)
+}
+
+// Permission read the user permission by id. The returned permission holds a
+// fresh resource token.
+func (u *Usr) Permission(ctx context.Context, id string, opts ...*RequestOptions) (*Permission, error) {
+	perm, err := u.db.c.ReadPermission(ctx, "dbs/"+u.db.Id+"/users/"+u.Id+"/permissions/"+id, opts...)
+	if err != nil {
+		return nil, err
+	} else if perm == nil {
+		return nil, ErrNotFound
+	}
+	return perm, nil
+}
+
+// Read user by self link
+func (c *DocumentDB) ReadUser(ctx context.Context, link string, opts ...*RequestOptions) (user *User, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &user, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read permission by self link
+func (c *DocumentDB) ReadPermission(ctx context.Context, link string, opts ...*RequestOptions) (perm *Permission, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.client.Query(ctx, link, nil, &perm, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Read all users by db self link
+func (c *DocumentDB) ReadUsers(ctx context.Context, db string, opts ...*RequestOptions) (users []User, err error) {
+	return c.QueryUsers(ctx, db, nil, opts...)
+}
+
+// Read all permissions by user self link
+func (c *DocumentDB) ReadPermissions(ctx context.Context, user string, opts ...*RequestOptions) (perms []Permission, err error) {
+	return c.QueryPermissions(ctx, user, nil, opts...)
+}
+
+// Read all db-users that satisfy a query
+func (c *DocumentDB) QueryUsers(ctx context.Context, db string, query *Query, opts ...*RequestOptions) (users []User, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Users []User `json:"Users,omitempty"`
+		Count int    `json:"_count,omitempty"`
+	}
+	_, err = c.client.Query(ctx, db+"users/", query, &data, headers)
+	if users = data.Users; err != nil {
+		users = nil
+	}
+	return
+}
+
+// Read all user permissions that satisfy a query
+func (c *DocumentDB) QueryPermissions(ctx context.Context, user string, query *Query, opts ...*RequestOptions) (perms []Permission, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Permissions []Permission `json:"Permissions,omitempty"`
+		Count       int          `json:"_count,omitempty"`
+	}
+	_, err = c.client.Query(ctx, user+"permissions/", query, &data, headers)
+	if perms = data.Permissions; err != nil {
+		perms = nil
+	}
+	return
+}
+
+// Create user
+func (c *DocumentDB) CreateUser(ctx context.Context, db string, body interface{}, opts ...*RequestOptions) (user *User, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, db+"users/", body, &user, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Create permission
+func (c *DocumentDB) CreatePermission(ctx context.Context, user string, body interface{}, opts ...*RequestOptions) (perm *Permission, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Create(ctx, user+"permissions/", body, &perm, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Replace user
+func (c *DocumentDB) ReplaceUser(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (user *User, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &user, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Replace permission
+func (c *DocumentDB) ReplacePermission(ctx context.Context, link string, body interface{}, opts ...*RequestOptions) (perm *Permission, err error) {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Replace(ctx, link, body, &perm, headers)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Delete user
+func (c *DocumentDB) DeleteUser(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
+}
+
+// Delete permission
+func (c *DocumentDB) DeletePermission(ctx context.Context, link string, opts ...*RequestOptions) error {
+	headers, err := optionsHeaders(nil, opts)
+	if err != nil {
+		return err
+	}
+	return c.client.Delete(ctx, link, headers)
+}
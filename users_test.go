@@ -0,0 +1,82 @@
+package documentdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReadUser(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "self_link", (*Query)(nil)).Return("", nil)
+	ctx := context.Background()
+	c.ReadUser(ctx, "self_link")
+	client.AssertCalled(t, "Query", "self_link", (*Query)(nil))
+	c.ReadPermission(ctx, "self_link")
+	client.AssertNumberOfCalls(t, "Query", 2)
+}
+
+func TestReadUsers(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "dblink/users/", (*Query)(nil)).Return("", nil)
+	ctx := context.Background()
+	c.ReadUsers(ctx, "dblink/")
+	client.AssertCalled(t, "Query", "dblink/users/", (*Query)(nil))
+}
+
+func TestQueryPermissions(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Query", "user_link/permissions/", &Query{Text: "SELECT * FROM ROOT r"}).Return("", nil)
+	ctx := context.Background()
+	c.QueryPermissions(ctx, "user_link/", &Query{Text: "SELECT * FROM ROOT r"})
+	client.AssertCalled(t, "Query", "user_link/permissions/", &Query{Text: "SELECT * FROM ROOT r"})
+}
+
+func TestCreateUser(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Create", "dbs/users/", `{"id":"a8m"}`).Return(nil)
+	ctx := context.Background()
+	c.CreateUser(ctx, "dbs/", `{"id":"a8m"}`)
+	client.AssertCalled(t, "Create", "dbs/users/", `{"id":"a8m"}`)
+}
+
+func TestCreatePermission(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Create", "user_link/permissions/", mock.Anything).Return(nil)
+	ctx := context.Background()
+	u := &Usr{db: &DB{c: c}, User: User{Resource: Resource{Self: "user_link/"}}}
+	u.CreatePermission(ctx, "read", PermissionRead, "coll_link/", "tenant")
+	perm := &Permission{PermissionMode: PermissionRead, ResourceLink: "coll_link/", ResourcePartitionKey: []interface{}{"tenant"}}
+	perm.Id = "read"
+	client.AssertCalled(t, "Create", "user_link/permissions/", perm)
+}
+
+func TestReplaceUser(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Replace", "user_link", "{}").Return(nil)
+	client.On("Replace", "perm_link", "{}").Return(nil)
+	ctx := context.Background()
+	c.ReplaceUser(ctx, "user_link", "{}")
+	c.ReplacePermission(ctx, "perm_link", "{}")
+	client.AssertCalled(t, "Replace", "user_link", "{}")
+	client.AssertCalled(t, "Replace", "perm_link", "{}")
+}
+
+func TestDeleteUser(t *testing.T) {
+	client := &ClientStub{}
+	c := &DocumentDB{client}
+	client.On("Delete", "user_link").Return(nil)
+	client.On("Delete", "perm_link").Return(nil)
+	ctx := context.Background()
+	c.DeleteUser(ctx, "user_link")
+	c.DeletePermission(ctx, "perm_link")
+	client.AssertCalled(t, "Delete", "user_link")
+	client.AssertCalled(t, "Delete", "perm_link")
+}